@@ -13,6 +13,7 @@ import (
 	"os"
 
 	"github.com/gorilla/mux"
+	"github.com/mongodb/mongodb-atlas-service-broker/pkg/atlas"
 	atlasbroker "github.com/mongodb/mongodb-atlas-service-broker/pkg/broker"
 	"github.com/pivotal-cf/brokerapi"
 )
@@ -26,6 +27,9 @@ const (
 
 	DefaultAtlasBaseURL = "https://cloud.mongodb.com"
 
+	ProjectModeShared      = "SHARED"
+	ProjectModePerInstance = "PER_INSTANCE"
+
 	DefaultServerHost = "127.0.0.1"
 	DefaultServerPort = 4000
 )
@@ -82,26 +86,89 @@ func startBrokerServer() {
 	}
 	defer logger.Sync() // Flushes buffer, if any
 
+	// Administrators can configure the MongoDB version offered to users.
+	// Bumping it allows existing instances to be upgraded using maintenance_info.
+	mongoDBVersion := getEnvOrDefault("ATLAS_MONGODB_VERSION", "")
+
+	// Administrators can choose the format of binding credentials to match
+	// the conventions of their platform.
+	credentialsFormat := getEnvOrDefault("BINDING_CREDENTIALS_FORMAT", atlasbroker.CredentialsFormatDefault)
+	if !atlasbroker.IsValidCredentialsFormat(credentialsFormat) {
+		logger.Fatalf("Invalid binding credentials format %q", credentialsFormat)
+	}
+
+	// Administrators can choose which role binding users get by default.
+	defaultRole := getEnvOrDefault("BINDING_DEFAULT_ROLE", atlasbroker.DefaultRolePolicy)
+	if !atlasbroker.IsValidRolePolicy(defaultRole) {
+		logger.Fatalf("Invalid binding default role %q", defaultRole)
+	}
+
+	// Administrators can adjust generated passwords to the tools and
+	// drivers used on their platform.
+	passwordPolicy := atlasbroker.PasswordPolicy{
+		Length:             getIntEnvOrDefault("BINDING_PASSWORD_LENGTH", atlasbroker.DefaultPasswordPolicy.Length),
+		CharacterClasses:   atlasbroker.ParsePasswordCharacterClasses(getEnvOrDefault("BINDING_PASSWORD_CHARACTERS", strings.Join(atlasbroker.DefaultPasswordPolicy.CharacterClasses, ","))),
+		ExcludedCharacters: getEnvOrDefault("BINDING_PASSWORD_EXCLUDED_CHARACTERS", ""),
+	}
+	if err := passwordPolicy.Validate(); err != nil {
+		logger.Fatalf("Invalid binding password policy: %v", err)
+	}
+
+	options := []atlasbroker.Option{
+		atlasbroker.WithMongoDBVersion(mongoDBVersion),
+		atlasbroker.WithCredentialsFormat(credentialsFormat),
+		atlasbroker.WithDefaultRolePolicy(defaultRole),
+		atlasbroker.WithPasswordGenerator(passwordPolicy),
+	}
+
 	// Administrators can control what providers/plans are available to users
 	pathToWhitelistFile, hasWhitelist := os.LookupEnv("PROVIDERS_WHITELIST_FILE")
 	var broker *atlasbroker.Broker
 	if !hasWhitelist {
-		broker = atlasbroker.NewBroker(logger)
+		broker = atlasbroker.NewBroker(logger, options...)
 	} else {
 		whitelist, err := atlasbroker.ReadWhitelistFile(pathToWhitelistFile)
 		if err != nil {
 			panic(err)
 		}
-		broker = atlasbroker.NewBrokerWithWhitelist(logger, whitelist)
+		broker = atlasbroker.NewBrokerWithWhitelist(logger, whitelist, options...)
 	}
 
 	router := mux.NewRouter()
 	brokerapi.AttachRoutes(router, broker, NewLagerZapLogger(logger))
+	atlasbroker.AttachAdminRoutes(router, broker)
+
+	// Binding rotation requests carry the predecessor binding which isn't
+	// parsed by brokerapi.
+	router.Use(atlasbroker.BindingRotationMiddleware())
 
 	// The auth middleware will convert basic auth credentials into an Atlas
-	// client.
+	// client. In project-per-instance mode the credentials are org-level API
+	// keys used to create a project for every instance. Brokers configured
+	// with their own credentials keep the API keys to themselves instead.
 	baseURL := strings.TrimRight(getEnvOrDefault("ATLAS_BASE_URL", DefaultAtlasBaseURL), "/")
-	router.Use(atlasbroker.AuthMiddleware(baseURL))
+	projectMode := getEnvOrDefault("ATLAS_PROJECT_MODE", ProjectModeShared)
+	credentials := getBrokerCredentials()
+	if credentials != nil {
+		router.Use(atlasbroker.CredentialsMiddleware(logger, baseURL, credentials))
+	} else {
+		switch projectMode {
+		case ProjectModeShared:
+			router.Use(atlasbroker.AuthMiddleware(baseURL))
+		case ProjectModePerInstance:
+			router.Use(atlasbroker.OrgAuthMiddleware(baseURL))
+		default:
+			logger.Fatalf("Invalid project mode %q, accepted values: %s, %s", projectMode, ProjectModeShared, ProjectModePerInstance)
+		}
+	}
+
+	// The scheduler pauses and resumes clusters according to their schedule.
+	// It needs its own API keys as it runs outside of platform requests.
+	schedulerClients := getSchedulerClients(baseURL)
+	if len(schedulerClients) > 0 {
+		scheduler := atlasbroker.NewScheduler(logger, schedulerClients)
+		go scheduler.Run(make(chan struct{}))
+	}
 
 	// Configure TLS from environment variables.
 	tlsEnabled, tlsCertPath, tlsKeyPath := getTLSConfig(logger)
@@ -113,7 +180,7 @@ func startBrokerServer() {
 	if !hasWhitelist {
 		pathToWhitelistFile = "NONE"
 	}
-	logger.Infow("Starting API server", "releaseVersion", releaseVersion, "host", host, "port", port, "tls_enabled", tlsEnabled, "atlas_base_url", baseURL, "whitelist_file", pathToWhitelistFile)
+	logger.Infow("Starting API server", "releaseVersion", releaseVersion, "host", host, "port", port, "tls_enabled", tlsEnabled, "atlas_base_url", baseURL, "project_mode", projectMode, "broker_credentials", credentials != nil, "whitelist_file", pathToWhitelistFile, "mongodb_version", mongoDBVersion, "credentials_format", credentialsFormat, "default_role", defaultRole, "password_length", passwordPolicy.Length, "password_characters", passwordPolicy.CharacterClasses, "scheduled_projects", len(schedulerClients))
 
 	// Start broker HTTP server.
 	address := host + ":" + strconv.Itoa(port)
@@ -146,6 +213,53 @@ func getTLSConfig(logger *zap.SugaredLogger) (bool, string, string) {
 	return hasCertPath && hasKeyPath, certPath, keyPath
 }
 
+// getBrokerCredentials will read the broker-held Atlas API keys and platform
+// credentials, either from the file (e.g. a mounted secret) specified by
+// BROKER_CREDENTIALS_FILE or as JSON from BROKER_CREDENTIALS. Returns nil if
+// neither is set.
+func getBrokerCredentials() *atlasbroker.Credentials {
+	var credentials *atlasbroker.Credentials
+	var err error
+
+	if path, ok := os.LookupEnv("BROKER_CREDENTIALS_FILE"); ok {
+		credentials, err = atlasbroker.ReadCredentialsFile(path)
+	} else if value, ok := os.LookupEnv("BROKER_CREDENTIALS"); ok {
+		credentials, err = atlasbroker.ParseCredentials([]byte(value))
+	}
+
+	if err != nil {
+		panic(err)
+	}
+
+	return credentials
+}
+
+// getSchedulerClients will create an Atlas client for every project the
+// scheduler should manage. The API keys are read from a comma-separated list
+// of "<PUBLIC_KEY>@<GROUP_ID>:<PRIVATE_KEY>" entries, matching the format
+// platforms use for basic auth.
+func getSchedulerClients(baseURL string) map[string]atlas.Client {
+	clients := make(map[string]atlas.Client)
+
+	value := getEnvOrDefault("SCHEDULER_ATLAS_API_KEYS", "")
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		credentials := strings.SplitN(entry, ":", 2)
+		username := strings.Split(credentials[0], "@")
+		if len(credentials) != 2 || len(username) != 2 || credentials[1] == "" {
+			panic(`Environment variable "SCHEDULER_ATLAS_API_KEYS" must contain entries formatted as "<PUBLIC_KEY>@<GROUP_ID>:<PRIVATE_KEY>"`)
+		}
+
+		clients[username[1]] = atlas.NewClient(baseURL, username[1], username[0], credentials[1])
+	}
+
+	return clients
+}
+
 // getEnvOrPanic will try getting an environment variable and fail with a
 // helpful error message in case it doesn't exist.
 func getEnvOrPanic(name string) string {
@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"net/http"
 	"net/url"
 )
@@ -16,11 +17,42 @@ type Client interface {
 	UpdateCluster(cluster Cluster) (*Cluster, error)
 	DeleteCluster(name string) error
 	GetCluster(name string) (*Cluster, error)
+	ListClusters() ([]Cluster, error)
 	GetDashboardURL(clusterName string) string
 
+	GetProcessArgs(clusterName string) (*ProcessArgs, error)
+	UpdateProcessArgs(clusterName string, args ProcessArgs) (*ProcessArgs, error)
+
+	ListSearchIndexes(clusterName string, database string, collection string) ([]SearchIndex, error)
+	CreateSearchIndex(clusterName string, index SearchIndex) (*SearchIndex, error)
+	UpdateSearchIndex(clusterName string, index SearchIndex) (*SearchIndex, error)
+	DeleteSearchIndex(clusterName string, indexID string) error
+
+	ListOnlineArchives(clusterName string) ([]OnlineArchive, error)
+	CreateOnlineArchive(clusterName string, archive OnlineArchive) (*OnlineArchive, error)
+	UpdateOnlineArchive(clusterName string, archive OnlineArchive) (*OnlineArchive, error)
+	DeleteOnlineArchive(clusterName string, archiveID string) error
+
+	CreateServerlessInstance(instance ServerlessInstance) (*ServerlessInstance, error)
+	UpdateServerlessInstance(instance ServerlessInstance) (*ServerlessInstance, error)
+	DeleteServerlessInstance(name string) error
+	GetServerlessInstance(name string) (*ServerlessInstance, error)
+
+	CreateFederatedDatabase(database FederatedDatabase) (*FederatedDatabase, error)
+	UpdateFederatedDatabase(database FederatedDatabase) (*FederatedDatabase, error)
+	DeleteFederatedDatabase(name string) error
+	GetFederatedDatabase(name string) (*FederatedDatabase, error)
+	GetFederatedDatabaseDashboardURL() string
+
 	CreateUser(user User) (*User, error)
 	GetUser(name string) (*User, error)
-	DeleteUser(name string) error
+	ListUsers() ([]User, error)
+	UpdateUser(user User) (*User, error)
+	DeleteUser(databaseName string, name string) error
+	CreateUserCertificate(name string, monthsUntilExpiration int) (string, error)
+
+	GetLDAPConfiguration() (*LDAPConfiguration, error)
+	UpdateLDAPConfiguration(config LDAPConfiguration) (*LDAPConfiguration, error)
 
 	GetProvider(name string) (*Provider, error)
 }
@@ -30,6 +62,7 @@ type Client interface {
 type HTTPClient struct {
 	BaseURL    string
 	GroupID    string
+	OrgID      string
 	PublicKey  string
 	PrivateKey string
 
@@ -45,10 +78,38 @@ var (
 	ErrClusterNotFound      = errors.New("Cluster not found")
 	ErrClusterAlreadyExists = errors.New("Cluster already exists")
 
+	ErrServerlessInstanceNotFound          = errors.New("Serverless instance not found")
+	ErrServerlessInstanceAlreadyExists     = errors.New("Serverless instance already exists")
+	ErrServerlessInstanceDeletionRequested = errors.New("Serverless instance is being deleted")
+
+	ErrFederatedDatabaseNotFound      = errors.New("Federated database instance not found")
+	ErrFederatedDatabaseAlreadyExists = errors.New("Federated database instance already exists")
+
+	ErrProjectNotFound      = errors.New("Project not found")
+	ErrProjectAlreadyExists = errors.New("Project already exists")
+
 	ErrUserNotFound      = errors.New("User not found")
 	ErrUserAlreadyExists = errors.New("User already exists")
 )
 
+// Error is an error returned by the Atlas API which doesn't match any of the
+// predefined errors.
+type Error struct {
+	StatusCode  int
+	Code        string
+	Description string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("atlas error: [%s] %s", e.Code, e.Description)
+}
+
+// IsRejected checks if Atlas rejected a request as invalid, as opposed to
+// failing to handle it. Retrying rejected requests won't help.
+func (e *Error) IsRejected() bool {
+	return e.StatusCode >= 400 && e.StatusCode < 500
+}
+
 const (
 	publicAPIPath  = "/api/atlas/v1.0"
 	privateAPIPath = "/api/private/unauth"
@@ -72,6 +133,13 @@ func (c *HTTPClient) requestPublic(method string, endpoint string, body interfac
 	return c.request(method, url, body, response)
 }
 
+// requestRoot will make a request to an endpoint in the public API which is
+// not scoped to a group, such as the projects of an organization.
+func (c *HTTPClient) requestRoot(method string, endpoint string, body interface{}, response interface{}) error {
+	url := fmt.Sprintf("%s%s/%s", c.BaseURL, publicAPIPath, endpoint)
+	return c.request(method, url, body, response)
+}
+
 // requestPrivate will make a request to an endpoint in the private API.
 func (c *HTTPClient) requestPrivate(method string, endpoint string, body interface{}, response interface{}) error {
 	url := fmt.Sprintf("%s%s/%s", c.BaseURL, privateAPIPath, endpoint)
@@ -119,6 +187,12 @@ func (c *HTTPClient) request(method string, url string, body interface{}, respon
 	// Decode response if request was successful.
 	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
 
+		// Some endpoints, such as for certificates, don't respond with JSON.
+		if raw, ok := response.(*[]byte); ok {
+			*raw, err = ioutil.ReadAll(resp.Body)
+			return err
+		}
+
 		if response != nil {
 			err = json.NewDecoder(resp.Body).Decode(response)
 
@@ -146,7 +220,7 @@ func (c *HTTPClient) request(method string, url string, body interface{}, respon
 		return err
 	}
 
-	return errorFromErrorCode(errorResponse.Code, errorResponse.Description)
+	return errorFromErrorCode(resp.StatusCode, errorResponse.Code, errorResponse.Description)
 }
 
 // digestAuth performs an unauthenticated request to retrieve a digest nonce.
@@ -180,13 +254,24 @@ func (c *HTTPClient) digestAuth(method string, endpoint string) (string, error)
 }
 
 // errorFromErrorCode converts an Atlas API error code into an error.
-func errorFromErrorCode(code string, description string) error {
+func errorFromErrorCode(statusCode int, code string, description string) error {
 	errorsByCode := map[string]error{
 		"CLUSTER_NOT_FOUND":                  ErrClusterNotFound,
 		"CLUSTER_ALREADY_REQUESTED_DELETION": ErrClusterNotFound,
 
 		"DUPLICATE_CLUSTER_NAME": ErrClusterAlreadyExists,
 
+		"SERVERLESS_INSTANCE_NOT_FOUND":                  ErrServerlessInstanceNotFound,
+		"SERVERLESS_INSTANCE_ALREADY_EXISTS":             ErrServerlessInstanceAlreadyExists,
+		"SERVERLESS_INSTANCE_ALREADY_REQUESTED_DELETION": ErrServerlessInstanceDeletionRequested,
+
+		"DATA_LAKE_TENANT_NOT_FOUND_FOR_NAME":  ErrFederatedDatabaseNotFound,
+		"DATA_LAKE_TENANT_NAME_ALREADY_EXISTS": ErrFederatedDatabaseAlreadyExists,
+
+		"GROUP_NOT_FOUND":      ErrProjectNotFound,
+		"GROUP_NAME_NOT_FOUND": ErrProjectNotFound,
+		"GROUP_ALREADY_EXISTS": ErrProjectAlreadyExists,
+
 		"USER_ALREADY_EXISTS": ErrUserAlreadyExists,
 		"USER_NOT_FOUND":      ErrUserNotFound,
 	}
@@ -194,7 +279,11 @@ func errorFromErrorCode(code string, description string) error {
 	// Default to an error wrapping the Atlas error description.
 	err := errorsByCode[code]
 	if err == nil {
-		return fmt.Errorf("atlas error: [%s] %s", code, description)
+		return &Error{
+			StatusCode:  statusCode,
+			Code:        code,
+			Description: description,
+		}
 	}
 
 	return err
@@ -21,8 +21,33 @@ func setupTest(t *testing.T, expectedPath string, method string, status int, res
 	const privateKey = "privkey"
 
 	fullPath := fmt.Sprintf("%s/groups/%s%s", publicAPIPath, groupID, expectedPath)
+	s := setupServer(t, fullPath, method, status, response)
 
-	s := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+	atlas := NewClient(s.URL, groupID, publicKey, privateKey)
+	atlas.HTTP = s.Client()
+
+	return atlas, s
+}
+
+// setupOrgTest is like setupTest but for endpoints which aren't scoped to a
+// group. The client is an org client for the organization "org".
+func setupOrgTest(t *testing.T, expectedPath string, method string, status int, response interface{}) (*HTTPClient, *httptest.Server) {
+	const orgID = "org"
+	const publicKey = "pubkey"
+	const privateKey = "privkey"
+
+	s := setupServer(t, publicAPIPath+expectedPath, method, status, response)
+
+	atlas := NewOrgClient(s.URL, orgID, publicKey, privateKey)
+	atlas.HTTP = s.Client()
+
+	return atlas, s
+}
+
+// setupServer will create the mock HTTP server used by setupTest and
+// setupOrgTest.
+func setupServer(t *testing.T, fullPath string, method string, status int, response interface{}) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
 		assert.Equal(t, fullPath, req.URL.String())
 		assert.Equal(t, method, req.Method)
 
@@ -34,18 +59,15 @@ func setupTest(t *testing.T, expectedPath string, method string, status int, res
 
 		rw.WriteHeader(status)
 
-		if response != nil {
+		if raw, ok := response.([]byte); ok {
+			rw.Write(raw)
+		} else if response != nil {
 			data, _ := json.Marshal(response)
 			rw.Write(data)
 		} else {
 			rw.Write([]byte{})
 		}
 	}))
-
-	atlas := NewClient(s.URL, groupID, publicKey, privateKey)
-	atlas.HTTP = s.Client()
-
-	return atlas, s
 }
 
 func errorResponse(code string) interface{} {
@@ -55,3 +77,16 @@ func errorResponse(code string) interface{} {
 		code,
 	}
 }
+
+func TestAtlasError(t *testing.T) {
+	atlas, server := setupTest(t, "/clusters/Cluster", http.MethodGet, 400, errorResponse("INVALID_ATTRIBUTE"))
+	defer server.Close()
+
+	_, err := atlas.GetCluster("Cluster")
+
+	atlasErr, ok := err.(*Error)
+	if assert.True(t, ok, "Expected an *Error, got %v", err) {
+		assert.Equal(t, "INVALID_ATTRIBUTE", atlasErr.Code)
+		assert.True(t, atlasErr.IsRejected())
+	}
+}
@@ -19,6 +19,7 @@ var (
 var (
 	ClusterTypeReplicaSet = "REPLICASET"
 	ClusterTypeSharded    = "SHARDED"
+	ClusterTypeGeoSharded = "GEOSHARDED"
 )
 
 // Cluster represents a single cluster in Atlas.
@@ -35,11 +36,30 @@ type Cluster struct {
 	NumShards                uint              `json:"numShards,omitempty"`
 	ProviderBackupEnabled    bool              `json:"providerBackupEnabled,omitempty"`
 	ReplicationSpecs         []ReplicationSpec `json:"replicationSpecs,omitempty"`
-	ProviderSettings         *ProviderSettings `json:"providerSettings"`
+	ProviderSettings         *ProviderSettings `json:"providerSettings,omitempty"`
+	Labels                   []Label           `json:"labels,omitempty"`
+
+	// Paused is a pointer as resuming a cluster requires explicitly sending
+	// "paused": false while leaving it out keeps the current state.
+	Paused *bool `json:"paused,omitempty"`
 
 	// Read-only attributes
-	StateName  string `json:"stateName,omitempty"`
-	SrvAddress string `json:"srvAddress,omitempty"`
+	StateName         string                    `json:"stateName,omitempty"`
+	SrvAddress        string                    `json:"srvAddress,omitempty"`
+	MongoURI          string                    `json:"mongoURI,omitempty"`
+	ConnectionStrings *ClusterConnectionStrings `json:"connectionStrings,omitempty"`
+}
+
+// ClusterConnectionStrings contains the connection strings of a cluster. The
+// standard connection string lists all hosts and includes the replica set.
+type ClusterConnectionStrings struct {
+	Standard    string `json:"standard,omitempty"`
+	StandardSrv string `json:"standardSrv,omitempty"`
+}
+
+// IsPaused returns whether the cluster is paused.
+func (c Cluster) IsPaused() bool {
+	return c.Paused != nil && *c.Paused
 }
 
 // AutoScalingConfig represents the autoscaling settings for a cluster.
@@ -76,6 +96,12 @@ type ReplicationSpec struct {
 	ZoneName      string                   `json:"zoneName,omitempty"`
 }
 
+// Label represents a key-value pair attached to a cluster.
+type Label struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
 // RegionsConfig represents a region's config in a replication spec.
 type RegionsConfig struct {
 	ElectableNodes int `json:"electableNodes"`
@@ -119,6 +145,19 @@ func (c *HTTPClient) GetCluster(name string) (*Cluster, error) {
 	return &cluster, err
 }
 
+// ListClusters will return all clusters in the project.
+// GET /clusters
+func (c *HTTPClient) ListClusters() ([]Cluster, error) {
+	// Atlas paginates results, 500 is the maximum page size.
+	path := "clusters?itemsPerPage=500"
+
+	var response struct {
+		Results []Cluster `json:"results"`
+	}
+	err := c.requestPublic(http.MethodGet, path, nil, &response)
+	return response.Results, err
+}
+
 // GetDashboardURL prepares the url where the specific cluster can be found in the Dashboard UI
 func (c *HTTPClient) GetDashboardURL(clusterName string) string {
 	return fmt.Sprintf("%s/v2/%s#clusters/detail/%s", c.BaseURL, c.GroupID, clusterName)
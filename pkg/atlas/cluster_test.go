@@ -1,6 +1,7 @@
 package atlas
 
 import (
+	"encoding/json"
 	"net/http"
 	"testing"
 
@@ -54,6 +55,14 @@ func TestUpdateCluster(t *testing.T) {
 	assert.Equal(t, &expected, cluster)
 }
 
+func TestUpdateClusterWithoutProviderSettings(t *testing.T) {
+	// Partial updates, e.g. version upgrades, must not reset the provider.
+	data, err := json.Marshal(Cluster{Name: "Cluster", MongoDBMajorVersion: "4.2"})
+
+	assert.NoError(t, err)
+	assert.NotContains(t, string(data), "providerSettings")
+}
+
 func TestUpdateNonexistentCluster(t *testing.T) {
 	expected := Cluster{
 		Name:        "Cluster",
@@ -85,6 +94,30 @@ func TestGetCluster(t *testing.T) {
 	assert.Equal(t, expected, cluster)
 }
 
+func TestListClusters(t *testing.T) {
+	expected := []Cluster{
+		Cluster{
+			Name:      "Cluster",
+			StateName: ClusterStateIdle,
+			Labels: []Label{
+				Label{Key: "key", Value: "value"},
+			},
+		},
+	}
+
+	response := struct {
+		Results []Cluster `json:"results"`
+	}{expected}
+
+	atlas, server := setupTest(t, "/clusters?itemsPerPage=500", http.MethodGet, 200, response)
+	defer server.Close()
+
+	clusters, err := atlas.ListClusters()
+
+	assert.NoError(t, err)
+	assert.Equal(t, expected, clusters)
+}
+
 func TestGetNonexistentCluster(t *testing.T) {
 	clusterName := "Cluster"
 	atlas, server := setupTest(t, "/clusters/"+clusterName, http.MethodGet, 404, errorResponse("CLUSTER_NOT_FOUND"))
@@ -0,0 +1,146 @@
+package atlas
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// All states a federated database instance can be in.
+var (
+	FederatedDatabaseStateActive     = "ACTIVE"
+	FederatedDatabaseStateUnverified = "UNVERIFIED"
+	FederatedDatabaseStateDeleted    = "DELETED"
+)
+
+// FederatedDatabase represents a single Atlas Data Federation instance.
+type FederatedDatabase struct {
+	Name string `json:"name"`
+
+	CloudProviderConfig *DataFederationCloudProviderConfig `json:"cloudProviderConfig,omitempty"`
+	DataProcessRegion   *DataFederationDataProcessRegion   `json:"dataProcessRegion,omitempty"`
+	Storage             *DataFederationStorage             `json:"storage,omitempty"`
+
+	// Read-only attributes
+	State     string   `json:"state,omitempty"`
+	Hostnames []string `json:"hostnames,omitempty"`
+}
+
+// DataFederationCloudProviderConfig represents the cloud provider access used
+// by a federated database instance to read from its stores.
+type DataFederationCloudProviderConfig struct {
+	AWS *DataFederationAWSConfig `json:"aws,omitempty"`
+}
+
+// DataFederationAWSConfig represents the IAM role a federated database
+// instance assumes when accessing S3.
+type DataFederationAWSConfig struct {
+	RoleID       string `json:"roleId"`
+	TestS3Bucket string `json:"testS3Bucket,omitempty"`
+}
+
+// DataFederationDataProcessRegion represents the region in which queries of a
+// federated database instance are processed.
+type DataFederationDataProcessRegion struct {
+	CloudProvider string `json:"cloudProvider"`
+	Region        string `json:"region"`
+}
+
+// DataFederationStorage maps the databases exposed by a federated database
+// instance to its underlying stores.
+type DataFederationStorage struct {
+	Databases []DataFederationDatabase `json:"databases,omitempty"`
+	Stores    []DataFederationStore    `json:"stores,omitempty"`
+}
+
+// DataFederationStore represents a single data store, such as an S3 bucket or
+// an Atlas cluster.
+type DataFederationStore struct {
+	Name     string `json:"name"`
+	Provider string `json:"provider"`
+
+	// Settings for S3 stores.
+	Bucket      string `json:"bucket,omitempty"`
+	Region      string `json:"region,omitempty"`
+	Prefix      string `json:"prefix,omitempty"`
+	Delimiter   string `json:"delimiter,omitempty"`
+	IncludeTags bool   `json:"includeTags,omitempty"`
+
+	// Settings for Atlas cluster stores.
+	ClusterName string `json:"clusterName,omitempty"`
+	ProjectID   string `json:"projectId,omitempty"`
+}
+
+// DataFederationDatabase represents a virtual database of a federated
+// database instance.
+type DataFederationDatabase struct {
+	Name        string                     `json:"name"`
+	Collections []DataFederationCollection `json:"collections,omitempty"`
+	Views       []DataFederationView       `json:"views,omitempty"`
+}
+
+// DataFederationCollection represents a virtual collection and the data
+// sources it is made up of.
+type DataFederationCollection struct {
+	Name        string                     `json:"name"`
+	DataSources []DataFederationDataSource `json:"dataSources,omitempty"`
+}
+
+// DataFederationDataSource represents a location in a store which data of a
+// virtual collection is read from.
+type DataFederationDataSource struct {
+	StoreName     string `json:"storeName"`
+	Path          string `json:"path,omitempty"`
+	DefaultFormat string `json:"defaultFormat,omitempty"`
+	AllowInsecure bool   `json:"allowInsecure,omitempty"`
+	Database      string `json:"database,omitempty"`
+	Collection    string `json:"collection,omitempty"`
+}
+
+// DataFederationView represents an aggregation pipeline exposed as a view.
+type DataFederationView struct {
+	Name     string `json:"name"`
+	Source   string `json:"source"`
+	Pipeline string `json:"pipeline"`
+}
+
+// CreateFederatedDatabase will create a new federated database instance.
+// POST /dataFederation
+func (c *HTTPClient) CreateFederatedDatabase(database FederatedDatabase) (*FederatedDatabase, error) {
+	var resultingDatabase FederatedDatabase
+	err := c.requestPublic(http.MethodPost, "dataFederation", database, &resultingDatabase)
+	return &resultingDatabase, err
+}
+
+// UpdateFederatedDatabase will update the configuration of a federated
+// database instance.
+// PATCH /dataFederation/{NAME}
+func (c *HTTPClient) UpdateFederatedDatabase(database FederatedDatabase) (*FederatedDatabase, error) {
+	path := fmt.Sprintf("dataFederation/%s?skipRoleValidation=false", database.Name)
+
+	var resultingDatabase FederatedDatabase
+	err := c.requestPublic(http.MethodPatch, path, database, &resultingDatabase)
+	return &resultingDatabase, err
+}
+
+// DeleteFederatedDatabase will remove a federated database instance.
+// DELETE /dataFederation/{NAME}
+func (c *HTTPClient) DeleteFederatedDatabase(name string) error {
+	path := fmt.Sprintf("dataFederation/%s", name)
+	return c.requestPublic(http.MethodDelete, path, nil, nil)
+}
+
+// GetFederatedDatabase will find a federated database instance by name.
+// GET /dataFederation/{NAME}
+func (c *HTTPClient) GetFederatedDatabase(name string) (*FederatedDatabase, error) {
+	path := fmt.Sprintf("dataFederation/%s", name)
+
+	var database FederatedDatabase
+	err := c.requestPublic(http.MethodGet, path, nil, &database)
+	return &database, err
+}
+
+// GetFederatedDatabaseDashboardURL prepares the url where the federated
+// database instances of the project can be found in the Dashboard UI
+func (c *HTTPClient) GetFederatedDatabaseDashboardURL() string {
+	return fmt.Sprintf("%s/v2/%s#/dataFederation", c.BaseURL, c.GroupID)
+}
@@ -0,0 +1,67 @@
+package atlas
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreateFederatedDatabase(t *testing.T) {
+	expected := FederatedDatabase{
+		Name:  "Database",
+		State: FederatedDatabaseStateActive,
+		Storage: &DataFederationStorage{
+			Stores: []DataFederationStore{
+				DataFederationStore{
+					Name:     "store",
+					Provider: "s3",
+					Bucket:   "bucket",
+					Region:   "us-east-1",
+				},
+			},
+		},
+	}
+
+	atlas, server := setupTest(t, "/dataFederation", http.MethodPost, 200, expected)
+	defer server.Close()
+
+	database, err := atlas.CreateFederatedDatabase(expected)
+
+	assert.NoError(t, err)
+	assert.Equal(t, &expected, database)
+}
+
+func TestUpdateFederatedDatabase(t *testing.T) {
+	expected := FederatedDatabase{
+		Name:  "Database",
+		State: FederatedDatabaseStateActive,
+	}
+
+	atlas, server := setupTest(t, "/dataFederation/"+expected.Name+"?skipRoleValidation=false", http.MethodPatch, 200, expected)
+	defer server.Close()
+
+	database, err := atlas.UpdateFederatedDatabase(expected)
+
+	assert.NoError(t, err)
+	assert.Equal(t, &expected, database)
+}
+
+func TestGetNonexistentFederatedDatabase(t *testing.T) {
+	name := "Database"
+	atlas, server := setupTest(t, "/dataFederation/"+name, http.MethodGet, 404, errorResponse("DATA_LAKE_TENANT_NOT_FOUND_FOR_NAME"))
+	defer server.Close()
+
+	_, err := atlas.GetFederatedDatabase(name)
+
+	assert.EqualError(t, err, ErrFederatedDatabaseNotFound.Error())
+}
+
+func TestDeleteFederatedDatabase(t *testing.T) {
+	name := "Database"
+	atlas, server := setupTest(t, "/dataFederation/"+name, http.MethodDelete, 204, nil)
+	defer server.Close()
+
+	err := atlas.DeleteFederatedDatabase(name)
+	assert.NoError(t, err)
+}
@@ -0,0 +1,49 @@
+package atlas
+
+import (
+	"net/http"
+)
+
+// LDAPConfiguration represents the LDAP settings of a project. Booleans are
+// pointers as leaving them out keeps the current value.
+type LDAPConfiguration struct {
+	AuthenticationEnabled *bool             `json:"authenticationEnabled,omitempty"`
+	AuthorizationEnabled  *bool             `json:"authorizationEnabled,omitempty"`
+	Hostname              string            `json:"hostname,omitempty"`
+	Port                  int               `json:"port,omitempty"`
+	BindUsername          string            `json:"bindUsername,omitempty"`
+	BindPassword          string            `json:"bindPassword,omitempty"`
+	CACertificate         string            `json:"caCertificate,omitempty"`
+	AuthzQueryTemplate    string            `json:"authzQueryTemplate,omitempty"`
+	UserToDNMapping       []LDAPUserMapping `json:"userToDNMapping,omitempty"`
+}
+
+// LDAPUserMapping maps usernames to LDAP distinguished names, either with a
+// substitution or an LDAP query.
+type LDAPUserMapping struct {
+	Match        string `json:"match"`
+	Substitution string `json:"substitution,omitempty"`
+	LDAPQuery    string `json:"ldapQuery,omitempty"`
+}
+
+// userSecurity is the body of the user security endpoints.
+type userSecurity struct {
+	LDAP LDAPConfiguration `json:"ldap"`
+}
+
+// GetLDAPConfiguration will return the LDAP settings of the project.
+// GET /userSecurity
+func (c *HTTPClient) GetLDAPConfiguration() (*LDAPConfiguration, error) {
+	var security userSecurity
+	err := c.requestPublic(http.MethodGet, "userSecurity", nil, &security)
+	return &security.LDAP, err
+}
+
+// UpdateLDAPConfiguration will change the LDAP settings of the project.
+// Settings which aren't set are left unchanged.
+// PATCH /userSecurity
+func (c *HTTPClient) UpdateLDAPConfiguration(config LDAPConfiguration) (*LDAPConfiguration, error) {
+	var security userSecurity
+	err := c.requestPublic(http.MethodPatch, "userSecurity", userSecurity{config}, &security)
+	return &security.LDAP, err
+}
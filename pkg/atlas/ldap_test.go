@@ -0,0 +1,45 @@
+package atlas
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetLDAPConfiguration(t *testing.T) {
+	enabled := true
+	expected := LDAPConfiguration{
+		AuthenticationEnabled: &enabled,
+		Hostname:              "ldap.example.com",
+		Port:                  636,
+	}
+
+	atlas, server := setupTest(t, "/userSecurity", http.MethodGet, 200, userSecurity{expected})
+	defer server.Close()
+
+	config, err := atlas.GetLDAPConfiguration()
+
+	assert.NoError(t, err)
+	assert.Equal(t, &expected, config)
+}
+
+func TestUpdateLDAPConfiguration(t *testing.T) {
+	enabled := true
+	expected := LDAPConfiguration{
+		AuthenticationEnabled: &enabled,
+		AuthorizationEnabled:  &enabled,
+		AuthzQueryTemplate:    "{USER}?memberOf?base",
+	}
+
+	atlas, server := setupTest(t, "/userSecurity", http.MethodPatch, 200, userSecurity{expected})
+	defer server.Close()
+
+	config, err := atlas.UpdateLDAPConfiguration(LDAPConfiguration{
+		AuthorizationEnabled: &enabled,
+		AuthzQueryTemplate:   "{USER}?memberOf?base",
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, &expected, config)
+}
@@ -14,7 +14,14 @@ type Provider struct {
 
 // InstanceSize represents an available cluster size.
 type InstanceSize struct {
-	Name string `json:"name"`
+	Name             string   `json:"name"`
+	AvailableRegions []Region `json:"availableRegions,omitempty"`
+}
+
+// Region represents a region in which an instance size is available.
+type Region struct {
+	Name    string `json:"name"`
+	Default bool   `json:"default,omitempty"`
 }
 
 // GetProvider will find a provider by name using the private API.
@@ -0,0 +1,103 @@
+package atlas
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// All states an online archive can be in.
+var (
+	OnlineArchiveStatePending  = "PENDING"
+	OnlineArchiveStateActive   = "ACTIVE"
+	OnlineArchiveStatePausing  = "PAUSING"
+	OnlineArchiveStatePaused   = "PAUSED"
+	OnlineArchiveStateDeleted  = "DELETED"
+	OnlineArchiveStateOrphaned = "ORPHANED"
+)
+
+// The types of criteria used to select documents to archive.
+var (
+	OnlineArchiveCriteriaDate   = "DATE"
+	OnlineArchiveCriteriaCustom = "CUSTOM"
+)
+
+// OnlineArchive represents a rule which moves documents of a collection to
+// cloud object storage.
+type OnlineArchive struct {
+	ID              string                   `json:"_id,omitempty"`
+	DBName          string                   `json:"dbName"`
+	CollName        string                   `json:"collName"`
+	Criteria        *OnlineArchiveCriteria   `json:"criteria,omitempty"`
+	PartitionFields []OnlineArchivePartition `json:"partitionFields,omitempty"`
+	Paused          bool                     `json:"paused,omitempty"`
+
+	// Read-only attributes
+	State string `json:"state,omitempty"`
+}
+
+// OnlineArchiveCriteria represents the criteria documents need to match to be
+// archived. Date criteria archive documents once the date in a field is older
+// than the specified number of days, custom criteria use a query.
+type OnlineArchiveCriteria struct {
+	Type            string `json:"type"`
+	DateField       string `json:"dateField,omitempty"`
+	DateFormat      string `json:"dateFormat,omitempty"`
+	ExpireAfterDays int    `json:"expireAfterDays,omitempty"`
+	Query           string `json:"query,omitempty"`
+}
+
+// OnlineArchivePartition represents a field used to partition archived data.
+type OnlineArchivePartition struct {
+	FieldName string `json:"fieldName"`
+	Order     int    `json:"order"`
+}
+
+// ListOnlineArchives will return all online archives of a cluster.
+// GET /clusters/{CLUSTER-NAME}/onlineArchives
+func (c *HTTPClient) ListOnlineArchives(clusterName string) ([]OnlineArchive, error) {
+	path := fmt.Sprintf("clusters/%s/onlineArchives", clusterName)
+
+	var response struct {
+		Results []OnlineArchive `json:"results"`
+	}
+	err := c.requestPublic(http.MethodGet, path, nil, &response)
+	return response.Results, err
+}
+
+// CreateOnlineArchive will create a new online archive for a collection.
+// POST /clusters/{CLUSTER-NAME}/onlineArchives
+func (c *HTTPClient) CreateOnlineArchive(clusterName string, archive OnlineArchive) (*OnlineArchive, error) {
+	path := fmt.Sprintf("clusters/%s/onlineArchives", clusterName)
+
+	var resultingArchive OnlineArchive
+	err := c.requestPublic(http.MethodPost, path, archive, &resultingArchive)
+	return &resultingArchive, err
+}
+
+// UpdateOnlineArchive will change the criteria of an online archive or pause
+// and resume it.
+// PATCH /clusters/{CLUSTER-NAME}/onlineArchives/{ARCHIVE-ID}
+func (c *HTTPClient) UpdateOnlineArchive(clusterName string, archive OnlineArchive) (*OnlineArchive, error) {
+	path := fmt.Sprintf("clusters/%s/onlineArchives/%s", clusterName, archive.ID)
+
+	// Only the criteria and paused state can be changed.
+	body := struct {
+		Criteria *OnlineArchiveCriteria `json:"criteria,omitempty"`
+		Paused   bool                   `json:"paused"`
+	}{
+		archive.Criteria,
+		archive.Paused,
+	}
+
+	var resultingArchive OnlineArchive
+	err := c.requestPublic(http.MethodPatch, path, body, &resultingArchive)
+	return &resultingArchive, err
+}
+
+// DeleteOnlineArchive will delete an online archive. Documents which have
+// already been archived are removed as well.
+// DELETE /clusters/{CLUSTER-NAME}/onlineArchives/{ARCHIVE-ID}
+func (c *HTTPClient) DeleteOnlineArchive(clusterName string, archiveID string) error {
+	path := fmt.Sprintf("clusters/%s/onlineArchives/%s", clusterName, archiveID)
+	return c.requestPublic(http.MethodDelete, path, nil, nil)
+}
@@ -0,0 +1,61 @@
+package atlas
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListOnlineArchives(t *testing.T) {
+	expected := []OnlineArchive{
+		OnlineArchive{
+			ID:       "id",
+			DBName:   "db",
+			CollName: "coll",
+			Criteria: &OnlineArchiveCriteria{
+				Type:            OnlineArchiveCriteriaDate,
+				DateField:       "created",
+				ExpireAfterDays: 30,
+			},
+			State: OnlineArchiveStateActive,
+		},
+	}
+
+	response := struct {
+		Results []OnlineArchive `json:"results"`
+	}{expected}
+
+	atlas, server := setupTest(t, "/clusters/Cluster/onlineArchives", http.MethodGet, 200, response)
+	defer server.Close()
+
+	archives, err := atlas.ListOnlineArchives("Cluster")
+
+	assert.NoError(t, err)
+	assert.Equal(t, expected, archives)
+}
+
+func TestCreateOnlineArchive(t *testing.T) {
+	expected := OnlineArchive{
+		ID:       "id",
+		DBName:   "db",
+		CollName: "coll",
+		State:    OnlineArchiveStatePending,
+	}
+
+	atlas, server := setupTest(t, "/clusters/Cluster/onlineArchives", http.MethodPost, 200, expected)
+	defer server.Close()
+
+	archive, err := atlas.CreateOnlineArchive("Cluster", expected)
+
+	assert.NoError(t, err)
+	assert.Equal(t, &expected, archive)
+}
+
+func TestDeleteOnlineArchive(t *testing.T) {
+	atlas, server := setupTest(t, "/clusters/Cluster/onlineArchives/id", http.MethodDelete, 204, nil)
+	defer server.Close()
+
+	err := atlas.DeleteOnlineArchive("Cluster", "id")
+	assert.NoError(t, err)
+}
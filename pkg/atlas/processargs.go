@@ -0,0 +1,48 @@
+package atlas
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// The TLS protocol versions which can be set as the minimum for a cluster.
+var (
+	TLSProtocol10 = "TLS1_0"
+	TLSProtocol11 = "TLS1_1"
+	TLSProtocol12 = "TLS1_2"
+)
+
+// ProcessArgs represents the advanced configuration options of a cluster.
+// Booleans are pointers as leaving them out keeps the current value.
+type ProcessArgs struct {
+	DefaultReadConcern               string `json:"defaultReadConcern,omitempty"`
+	DefaultWriteConcern              string `json:"defaultWriteConcern,omitempty"`
+	FailIndexKeyTooLong              *bool  `json:"failIndexKeyTooLong,omitempty"`
+	JavascriptEnabled                *bool  `json:"javascriptEnabled,omitempty"`
+	MinimumEnabledTLSProtocol        string `json:"minimumEnabledTlsProtocol,omitempty"`
+	NoTableScan                      *bool  `json:"noTableScan,omitempty"`
+	OplogSizeMB                      int    `json:"oplogSizeMB,omitempty"`
+	SampleSizeBIConnector            int    `json:"sampleSizeBIConnector,omitempty"`
+	SampleRefreshIntervalBIConnector int    `json:"sampleRefreshIntervalBIConnector,omitempty"`
+}
+
+// GetProcessArgs will return the advanced configuration options of a cluster.
+// GET /clusters/{CLUSTER-NAME}/processArgs
+func (c *HTTPClient) GetProcessArgs(clusterName string) (*ProcessArgs, error) {
+	path := fmt.Sprintf("clusters/%s/processArgs", clusterName)
+
+	var args ProcessArgs
+	err := c.requestPublic(http.MethodGet, path, nil, &args)
+	return &args, err
+}
+
+// UpdateProcessArgs will change the advanced configuration options of a
+// cluster. Options which aren't set are left unchanged.
+// PATCH /clusters/{CLUSTER-NAME}/processArgs
+func (c *HTTPClient) UpdateProcessArgs(clusterName string, args ProcessArgs) (*ProcessArgs, error) {
+	path := fmt.Sprintf("clusters/%s/processArgs", clusterName)
+
+	var resultingArgs ProcessArgs
+	err := c.requestPublic(http.MethodPatch, path, args, &resultingArgs)
+	return &resultingArgs, err
+}
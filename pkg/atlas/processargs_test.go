@@ -0,0 +1,50 @@
+package atlas
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetProcessArgs(t *testing.T) {
+	enabled := true
+	expected := &ProcessArgs{
+		DefaultReadConcern:        "majority",
+		JavascriptEnabled:         &enabled,
+		MinimumEnabledTLSProtocol: TLSProtocol12,
+	}
+
+	atlas, server := setupTest(t, "/clusters/Cluster/processArgs", http.MethodGet, 200, expected)
+	defer server.Close()
+
+	args, err := atlas.GetProcessArgs("Cluster")
+
+	assert.NoError(t, err)
+	assert.Equal(t, expected, args)
+}
+
+func TestGetProcessArgsNonexistentCluster(t *testing.T) {
+	atlas, server := setupTest(t, "/clusters/Cluster/processArgs", http.MethodGet, 404, errorResponse("CLUSTER_NOT_FOUND"))
+	defer server.Close()
+
+	_, err := atlas.GetProcessArgs("Cluster")
+
+	assert.EqualError(t, err, ErrClusterNotFound.Error())
+}
+
+func TestUpdateProcessArgs(t *testing.T) {
+	disabled := false
+	expected := ProcessArgs{
+		FailIndexKeyTooLong: &disabled,
+		OplogSizeMB:         2048,
+	}
+
+	atlas, server := setupTest(t, "/clusters/Cluster/processArgs", http.MethodPatch, 200, expected)
+	defer server.Close()
+
+	args, err := atlas.UpdateProcessArgs("Cluster", expected)
+
+	assert.NoError(t, err)
+	assert.Equal(t, &expected, args)
+}
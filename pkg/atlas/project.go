@@ -0,0 +1,75 @@
+package atlas
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// OrgClient is an interface for interacting with the parts of the Atlas API
+// which are scoped to an organization rather than a project. It requires
+// organization-level API keys.
+type OrgClient interface {
+	CreateProject(name string) (*Project, error)
+	GetProjectByName(name string) (*Project, error)
+	DeleteProject(id string) error
+
+	// ProjectClient returns a client for a project in the organization
+	// using the same API keys.
+	ProjectClient(groupID string) Client
+}
+
+// Project represents a single project (also known as group) in Atlas.
+type Project struct {
+	ID    string `json:"id,omitempty"`
+	Name  string `json:"name"`
+	OrgID string `json:"orgId"`
+
+	// Read-only attributes
+	ClusterCount int `json:"clusterCount,omitempty"`
+}
+
+// NewOrgClient will create a new HTTPClient for an organization. The client
+// is not scoped to a project until ProjectClient is used.
+func NewOrgClient(baseURL string, orgID string, publicKey string, privateKey string) *HTTPClient {
+	client := NewClient(baseURL, "", publicKey, privateKey)
+	client.OrgID = orgID
+	return client
+}
+
+// CreateProject will create a new project in the client's organization.
+// POST /groups
+func (c *HTTPClient) CreateProject(name string) (*Project, error) {
+	project := Project{
+		Name:  name,
+		OrgID: c.OrgID,
+	}
+
+	var resultingProject Project
+	err := c.requestRoot(http.MethodPost, "groups", project, &resultingProject)
+	return &resultingProject, err
+}
+
+// GetProjectByName will find a project by name.
+// GET /groups/byName/{GROUP-NAME}
+func (c *HTTPClient) GetProjectByName(name string) (*Project, error) {
+	path := fmt.Sprintf("groups/byName/%s", name)
+
+	var project Project
+	err := c.requestRoot(http.MethodGet, path, nil, &project)
+	return &project, err
+}
+
+// DeleteProject will delete a project. Atlas only allows deleting projects
+// without any clusters.
+// DELETE /groups/{GROUP-ID}
+func (c *HTTPClient) DeleteProject(id string) error {
+	path := fmt.Sprintf("groups/%s", id)
+	return c.requestRoot(http.MethodDelete, path, nil, nil)
+}
+
+// ProjectClient returns a client for a project using the same API keys.
+func (c *HTTPClient) ProjectClient(groupID string) Client {
+	client := *c
+	client.GroupID = groupID
+	return &client
+}
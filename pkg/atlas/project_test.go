@@ -0,0 +1,77 @@
+package atlas
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreateProject(t *testing.T) {
+	expected := &Project{
+		ID:    "group",
+		Name:  "Project",
+		OrgID: "org",
+	}
+
+	atlas, server := setupOrgTest(t, "/groups", http.MethodPost, 201, expected)
+	defer server.Close()
+
+	project, err := atlas.CreateProject("Project")
+
+	assert.NoError(t, err)
+	assert.Equal(t, expected, project)
+}
+
+func TestCreateProjectExistingName(t *testing.T) {
+	atlas, server := setupOrgTest(t, "/groups", http.MethodPost, 409, errorResponse("GROUP_ALREADY_EXISTS"))
+	defer server.Close()
+
+	_, err := atlas.CreateProject("Project")
+
+	assert.EqualError(t, err, ErrProjectAlreadyExists.Error())
+}
+
+func TestGetProjectByName(t *testing.T) {
+	expected := &Project{
+		ID:    "group",
+		Name:  "Project",
+		OrgID: "org",
+	}
+
+	atlas, server := setupOrgTest(t, "/groups/byName/Project", http.MethodGet, 200, expected)
+	defer server.Close()
+
+	project, err := atlas.GetProjectByName("Project")
+
+	assert.NoError(t, err)
+	assert.Equal(t, expected, project)
+}
+
+func TestGetNonexistentProject(t *testing.T) {
+	atlas, server := setupOrgTest(t, "/groups/byName/Project", http.MethodGet, 404, errorResponse("GROUP_NAME_NOT_FOUND"))
+	defer server.Close()
+
+	_, err := atlas.GetProjectByName("Project")
+
+	assert.EqualError(t, err, ErrProjectNotFound.Error())
+}
+
+func TestDeleteProject(t *testing.T) {
+	atlas, server := setupOrgTest(t, "/groups/group", http.MethodDelete, 202, nil)
+	defer server.Close()
+
+	err := atlas.DeleteProject("group")
+
+	assert.NoError(t, err)
+}
+
+func TestProjectClient(t *testing.T) {
+	atlas := NewOrgClient("http://atlas", "org", "pubkey", "privkey")
+
+	client := atlas.ProjectClient("group").(*HTTPClient)
+
+	assert.Equal(t, "group", client.GroupID)
+	assert.Equal(t, "pubkey", client.PublicKey)
+	assert.Empty(t, atlas.GroupID, "Expected the org client to not be modified")
+}
@@ -0,0 +1,73 @@
+package atlas
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// All states an Atlas Search index can be in.
+var (
+	SearchIndexStatusInProgress = "IN_PROGRESS"
+	SearchIndexStatusMigrating  = "MIGRATING"
+	SearchIndexStatusSteady     = "STEADY"
+	SearchIndexStatusFailed     = "FAILED"
+)
+
+// SearchIndex represents a single Atlas Search index on a collection.
+type SearchIndex struct {
+	IndexID        string               `json:"indexID,omitempty"`
+	Name           string               `json:"name"`
+	Database       string               `json:"database"`
+	CollectionName string               `json:"collectionName"`
+	Analyzer       string               `json:"analyzer,omitempty"`
+	SearchAnalyzer string               `json:"searchAnalyzer,omitempty"`
+	Mappings       *SearchIndexMappings `json:"mappings,omitempty"`
+
+	// Read-only attributes
+	Status string `json:"status,omitempty"`
+}
+
+// SearchIndexMappings represents the field mappings of a search index.
+type SearchIndexMappings struct {
+	Dynamic bool                   `json:"dynamic"`
+	Fields  map[string]interface{} `json:"fields,omitempty"`
+}
+
+// ListSearchIndexes will return all search indexes on a collection.
+// GET /clusters/{CLUSTER-NAME}/fts/indexes/{DATABASE}/{COLLECTION}
+func (c *HTTPClient) ListSearchIndexes(clusterName string, database string, collection string) ([]SearchIndex, error) {
+	path := fmt.Sprintf("clusters/%s/fts/indexes/%s/%s", clusterName, database, collection)
+
+	var indexes []SearchIndex
+	err := c.requestPublic(http.MethodGet, path, nil, &indexes)
+	return indexes, err
+}
+
+// CreateSearchIndex will create a new search index. The index is built
+// asynchronously.
+// POST /clusters/{CLUSTER-NAME}/fts/indexes
+func (c *HTTPClient) CreateSearchIndex(clusterName string, index SearchIndex) (*SearchIndex, error) {
+	path := fmt.Sprintf("clusters/%s/fts/indexes", clusterName)
+
+	var resultingIndex SearchIndex
+	err := c.requestPublic(http.MethodPost, path, index, &resultingIndex)
+	return &resultingIndex, err
+}
+
+// UpdateSearchIndex will update an existing search index, which causes it to
+// be rebuilt asynchronously.
+// PATCH /clusters/{CLUSTER-NAME}/fts/indexes/{INDEX-ID}
+func (c *HTTPClient) UpdateSearchIndex(clusterName string, index SearchIndex) (*SearchIndex, error) {
+	path := fmt.Sprintf("clusters/%s/fts/indexes/%s", clusterName, index.IndexID)
+
+	var resultingIndex SearchIndex
+	err := c.requestPublic(http.MethodPatch, path, index, &resultingIndex)
+	return &resultingIndex, err
+}
+
+// DeleteSearchIndex will delete a search index.
+// DELETE /clusters/{CLUSTER-NAME}/fts/indexes/{INDEX-ID}
+func (c *HTTPClient) DeleteSearchIndex(clusterName string, indexID string) error {
+	path := fmt.Sprintf("clusters/%s/fts/indexes/%s", clusterName, indexID)
+	return c.requestPublic(http.MethodDelete, path, nil, nil)
+}
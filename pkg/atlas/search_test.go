@@ -0,0 +1,55 @@
+package atlas
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListSearchIndexes(t *testing.T) {
+	expected := []SearchIndex{
+		SearchIndex{
+			IndexID:        "id",
+			Name:           "default",
+			Database:       "db",
+			CollectionName: "coll",
+			Mappings:       &SearchIndexMappings{Dynamic: true},
+			Status:         SearchIndexStatusSteady,
+		},
+	}
+
+	atlas, server := setupTest(t, "/clusters/Cluster/fts/indexes/db/coll", http.MethodGet, 200, expected)
+	defer server.Close()
+
+	indexes, err := atlas.ListSearchIndexes("Cluster", "db", "coll")
+
+	assert.NoError(t, err)
+	assert.Equal(t, expected, indexes)
+}
+
+func TestCreateSearchIndex(t *testing.T) {
+	expected := SearchIndex{
+		IndexID:        "id",
+		Name:           "default",
+		Database:       "db",
+		CollectionName: "coll",
+		Status:         SearchIndexStatusInProgress,
+	}
+
+	atlas, server := setupTest(t, "/clusters/Cluster/fts/indexes", http.MethodPost, 200, expected)
+	defer server.Close()
+
+	index, err := atlas.CreateSearchIndex("Cluster", expected)
+
+	assert.NoError(t, err)
+	assert.Equal(t, &expected, index)
+}
+
+func TestDeleteSearchIndex(t *testing.T) {
+	atlas, server := setupTest(t, "/clusters/Cluster/fts/indexes/id", http.MethodDelete, 204, nil)
+	defer server.Close()
+
+	err := atlas.DeleteSearchIndex("Cluster", "id")
+	assert.NoError(t, err)
+}
@@ -0,0 +1,88 @@
+package atlas
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// ServerlessInstance represents a single serverless instance in Atlas.
+type ServerlessInstance struct {
+	Name string `json:"name"`
+
+	ProviderSettings        *ServerlessProviderSettings `json:"providerSettings,omitempty"`
+	ServerlessBackupOptions *ServerlessBackupOptions    `json:"serverlessBackupOptions,omitempty"`
+
+	// TerminationProtectionEnabled is a pointer as disabling termination
+	// protection requires explicitly sending false while leaving it out keeps
+	// the current setting.
+	TerminationProtectionEnabled *bool `json:"terminationProtectionEnabled,omitempty"`
+
+	// Read-only attributes
+	StateName         string                       `json:"stateName,omitempty"`
+	MongoDBVersion    string                       `json:"mongoDBVersion,omitempty"`
+	ConnectionStrings *ServerlessConnectionStrings `json:"connectionStrings,omitempty"`
+}
+
+// ServerlessProviderSettings represents the cloud provider and region a
+// serverless instance is deployed to.
+type ServerlessProviderSettings struct {
+	ProviderName        string `json:"providerName"`
+	BackingProviderName string `json:"backingProviderName"`
+	RegionName          string `json:"regionName"`
+}
+
+// ServerlessBackupOptions represents the backup settings for a serverless
+// instance.
+type ServerlessBackupOptions struct {
+	ServerlessContinuousBackupEnabled bool `json:"serverlessContinuousBackupEnabled"`
+}
+
+// ServerlessConnectionStrings contains the connection strings of a serverless
+// instance.
+type ServerlessConnectionStrings struct {
+	StandardSrv string `json:"standardSrv,omitempty"`
+}
+
+// ProviderNameServerless is the provider name used for all serverless
+// instances. The cloud provider is specified as the backing provider.
+const ProviderNameServerless = "SERVERLESS"
+
+// CreateServerlessInstance will create a new serverless instance
+// asynchronously.
+// POST /serverless
+func (c *HTTPClient) CreateServerlessInstance(instance ServerlessInstance) (*ServerlessInstance, error) {
+	var resultingInstance ServerlessInstance
+	err := c.requestPublic(http.MethodPost, "serverless", instance, &resultingInstance)
+	return &resultingInstance, err
+}
+
+// UpdateServerlessInstance will update a serverless instance asynchronously.
+// PATCH /serverless/{INSTANCE-NAME}
+func (c *HTTPClient) UpdateServerlessInstance(instance ServerlessInstance) (*ServerlessInstance, error) {
+	path := fmt.Sprintf("serverless/%s", instance.Name)
+
+	// The provider settings can't be changed after creation.
+	instance.ProviderSettings = nil
+
+	var resultingInstance ServerlessInstance
+	err := c.requestPublic(http.MethodPatch, path, instance, &resultingInstance)
+	return &resultingInstance, err
+}
+
+// DeleteServerlessInstance will terminate a serverless instance
+// asynchronously.
+// DELETE /serverless/{INSTANCE-NAME}
+func (c *HTTPClient) DeleteServerlessInstance(name string) error {
+	path := fmt.Sprintf("serverless/%s", name)
+	return c.requestPublic(http.MethodDelete, path, nil, nil)
+}
+
+// GetServerlessInstance will find a serverless instance by name.
+// GET /serverless/{INSTANCE-NAME}
+func (c *HTTPClient) GetServerlessInstance(name string) (*ServerlessInstance, error) {
+	path := fmt.Sprintf("serverless/%s", name)
+
+	var instance ServerlessInstance
+	err := c.requestPublic(http.MethodGet, path, nil, &instance)
+	return &instance, err
+}
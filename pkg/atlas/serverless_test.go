@@ -0,0 +1,88 @@
+package atlas
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreateServerlessInstance(t *testing.T) {
+	expected := ServerlessInstance{
+		Name:      "Instance",
+		StateName: ClusterStateCreating,
+		ProviderSettings: &ServerlessProviderSettings{
+			ProviderName:        ProviderNameServerless,
+			BackingProviderName: "AWS",
+			RegionName:          "US_EAST_1",
+		},
+	}
+
+	atlas, server := setupTest(t, "/serverless", http.MethodPost, 200, expected)
+	defer server.Close()
+
+	instance, err := atlas.CreateServerlessInstance(expected)
+
+	assert.NoError(t, err)
+	assert.Equal(t, &expected, instance)
+}
+
+func TestCreateServerlessInstanceExistingName(t *testing.T) {
+	instance := ServerlessInstance{
+		Name: "Instance",
+	}
+
+	atlas, server := setupTest(t, "/serverless", http.MethodPost, 400, errorResponse("SERVERLESS_INSTANCE_ALREADY_EXISTS"))
+	defer server.Close()
+
+	_, err := atlas.CreateServerlessInstance(instance)
+
+	assert.EqualError(t, err, ErrServerlessInstanceAlreadyExists.Error())
+}
+
+func TestGetServerlessInstance(t *testing.T) {
+	expected := &ServerlessInstance{
+		Name:      "Instance",
+		StateName: ClusterStateIdle,
+		ConnectionStrings: &ServerlessConnectionStrings{
+			StandardSrv: "mongodb+srv://instance.mongodb.net",
+		},
+	}
+
+	atlas, server := setupTest(t, "/serverless/"+expected.Name, http.MethodGet, 200, expected)
+	defer server.Close()
+
+	instance, err := atlas.GetServerlessInstance(expected.Name)
+
+	assert.NoError(t, err)
+	assert.Equal(t, expected, instance)
+}
+
+func TestGetNonexistentServerlessInstance(t *testing.T) {
+	name := "Instance"
+	atlas, server := setupTest(t, "/serverless/"+name, http.MethodGet, 404, errorResponse("SERVERLESS_INSTANCE_NOT_FOUND"))
+	defer server.Close()
+
+	_, err := atlas.GetServerlessInstance(name)
+
+	assert.EqualError(t, err, ErrServerlessInstanceNotFound.Error())
+}
+
+func TestDeleteServerlessInstance(t *testing.T) {
+	name := "Instance"
+	atlas, server := setupTest(t, "/serverless/"+name, http.MethodDelete, 202, nil)
+	defer server.Close()
+
+	err := atlas.DeleteServerlessInstance(name)
+	assert.NoError(t, err)
+}
+
+func TestDeleteServerlessInstanceBeingDeleted(t *testing.T) {
+	name := "Instance"
+	atlas, server := setupTest(t, "/serverless/"+name, http.MethodDelete, 400, errorResponse("SERVERLESS_INSTANCE_ALREADY_REQUESTED_DELETION"))
+	defer server.Close()
+
+	err := atlas.DeleteServerlessInstance(name)
+
+	assert.EqualError(t, err, ErrServerlessInstanceDeletionRequested.Error())
+}
@@ -3,15 +3,76 @@ package atlas
 import (
 	"fmt"
 	"net/http"
+	"net/url"
 )
 
 // User represents a single Atlas database user.
 type User struct {
-	Username     string `json:"username"`
-	Password     string `json:"password"`
-	DatabaseName string `json:"databaseName"`
-	LDAPAuthType string `json:"ldapAuthType,omitempty"`
-	Roles        []Role `json:"roles,omitempty"`
+	Username     string  `json:"username"`
+	Password     string  `json:"password,omitempty"`
+	DatabaseName string  `json:"databaseName"`
+	LDAPAuthType string  `json:"ldapAuthType,omitempty"`
+	Roles        []Role  `json:"roles,omitempty"`
+	Scopes       []Scope `json:"scopes,omitempty"`
+	Labels       []Label `json:"labels,omitempty"`
+
+	// DeleteAfterDate is the time in ISO 8601 format after which Atlas
+	// deletes the user.
+	DeleteAfterDate string `json:"deleteAfterDate,omitempty"`
+
+	// X509Type is set for users authenticating with X.509 certificates.
+	X509Type string `json:"x509Type,omitempty"`
+
+	// AWSIAMType is set for users authenticating with an AWS IAM user or
+	// role, whose ARN is the username.
+	AWSIAMType string `json:"awsIAMType,omitempty"`
+}
+
+// The authentication databases of database users. Users authenticating with
+// a password use "admin", all others are external users.
+var (
+	AuthDatabaseAdmin    = "admin"
+	AuthDatabaseExternal = "$external"
+)
+
+// The types of X.509 authentication. Atlas issues the certificates of
+// managed users itself.
+var (
+	X509TypeNone    = "NONE"
+	X509TypeManaged = "MANAGED"
+)
+
+// The types of AWS IAM authentication.
+var (
+	AWSIAMTypeNone = "NONE"
+	AWSIAMTypeUser = "USER"
+	AWSIAMTypeRole = "ROLE"
+)
+
+// The types of LDAP authentication. LDAP groups grant their roles to all
+// LDAP users in the group.
+var (
+	LDAPAuthTypeNone  = "NONE"
+	LDAPAuthTypeUser  = "USER"
+	LDAPAuthTypeGroup = "GROUP"
+)
+
+// AuthDatabase returns the authentication database Atlas expects for a user.
+// Only users authenticating with a password use "admin".
+func (u User) AuthDatabase() string {
+	if u.X509Type != "" && u.X509Type != X509TypeNone {
+		return AuthDatabaseExternal
+	}
+
+	if u.AWSIAMType != "" && u.AWSIAMType != AWSIAMTypeNone {
+		return AuthDatabaseExternal
+	}
+
+	if u.LDAPAuthType != "" && u.LDAPAuthType != LDAPAuthTypeNone {
+		return AuthDatabaseExternal
+	}
+
+	return AuthDatabaseAdmin
 }
 
 // Role represents the role of a database user.
@@ -21,12 +82,25 @@ type Role struct {
 	CollectionName string `json:"collectionName,omitempty"`
 }
 
+// The types of resources a database user can be scoped to.
+var (
+	ScopeTypeCluster  = "CLUSTER"
+	ScopeTypeDataLake = "DATA_LAKE"
+)
+
+// Scope represents a cluster or federated database instance a database user
+// is restricted to. Users without scopes can access all resources in a project.
+type Scope struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
 // CreateUser will create a new database user with read/write access to all
 // databases.
 // Endpoint: POST /databaseUsers
 func (c *HTTPClient) CreateUser(user User) (*User, error) {
-	// Atlas always uses "admin" for the authentication database.
-	user.DatabaseName = "admin"
+	// Atlas only accepts "admin" or "$external" depending on the type of user.
+	user.DatabaseName = user.AuthDatabase()
 
 	var resultingUser User
 	err := c.requestPublic(http.MethodPost, "databaseUsers", user, &resultingUser)
@@ -36,16 +110,64 @@ func (c *HTTPClient) CreateUser(user User) (*User, error) {
 // GetUser will find a database user by its username.
 // GET /databaseUsers/admin/{USERNAME}
 func (c *HTTPClient) GetUser(name string) (*User, error) {
-	path := fmt.Sprintf("databaseUsers/admin/%s", name)
+	path := fmt.Sprintf("databaseUsers/admin/%s", url.PathEscape(name))
 
 	var user User
 	err := c.requestPublic(http.MethodGet, path, nil, &user)
 	return &user, err
 }
 
-// DeleteUser will delete an existing database user.
-// Endpoint: DELETE /databaseUsers/{USERNAME}
-func (c *HTTPClient) DeleteUser(name string) error {
-	path := fmt.Sprintf("databaseUsers/admin/%s", name)
+// ListUsers will list all database users in the project.
+// GET /databaseUsers
+func (c *HTTPClient) ListUsers() ([]User, error) {
+	var response struct {
+		Results []User `json:"results"`
+	}
+
+	err := c.requestPublic(http.MethodGet, "databaseUsers?itemsPerPage=500", nil, &response)
+	return response.Results, err
+}
+
+// UpdateUser will update an existing database user, e.g. to change its
+// password. Only the fields which are set are changed. Users are expected in
+// the "admin" database unless DatabaseName is set.
+// PATCH /databaseUsers/{DATABASE}/{USERNAME}
+func (c *HTTPClient) UpdateUser(user User) (*User, error) {
+	if user.DatabaseName == "" {
+		user.DatabaseName = AuthDatabaseAdmin
+	}
+
+	path := fmt.Sprintf("databaseUsers/%s/%s", user.DatabaseName, url.PathEscape(user.Username))
+
+	var resultingUser User
+	err := c.requestPublic(http.MethodPatch, path, user, &resultingUser)
+	return &resultingUser, err
+}
+
+// DeleteUser will delete an existing database user from its authentication
+// database. An empty database defaults to "admin". Usernames are escaped as
+// they may contain slashes, such as AWS IAM ARNs.
+// Endpoint: DELETE /databaseUsers/{DATABASE}/{USERNAME}
+func (c *HTTPClient) DeleteUser(databaseName string, name string) error {
+	if databaseName == "" {
+		databaseName = AuthDatabaseAdmin
+	}
+
+	path := fmt.Sprintf("databaseUsers/%s/%s", databaseName, url.PathEscape(name))
 	return c.requestPublic(http.MethodDelete, path, nil, nil)
 }
+
+// CreateUserCertificate will issue a new client certificate for a database
+// user with X509Type set to X509TypeManaged. The certificate and its private
+// key are returned PEM encoded.
+// Endpoint: POST /databaseUsers/{USERNAME}/certs
+func (c *HTTPClient) CreateUserCertificate(name string, monthsUntilExpiration int) (string, error) {
+	path := fmt.Sprintf("databaseUsers/%s/certs", url.PathEscape(name))
+	body := struct {
+		MonthsUntilExpiration int `json:"monthsUntilExpiration"`
+	}{monthsUntilExpiration}
+
+	var pem []byte
+	err := c.requestPublic(http.MethodPost, path, body, &pem)
+	return string(pem), err
+}
@@ -0,0 +1,113 @@
+package atlas
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListUsers(t *testing.T) {
+	expected := []User{
+		User{Username: "user", DatabaseName: "admin"},
+	}
+
+	response := struct {
+		Results []User `json:"results"`
+	}{expected}
+
+	atlas, server := setupTest(t, "/databaseUsers?itemsPerPage=500", http.MethodGet, 200, response)
+	defer server.Close()
+
+	users, err := atlas.ListUsers()
+
+	assert.NoError(t, err)
+	assert.Equal(t, expected, users)
+}
+
+func TestUpdateUser(t *testing.T) {
+	expected := User{
+		Username:     "user",
+		Password:     "password",
+		DatabaseName: "admin",
+	}
+
+	atlas, server := setupTest(t, "/databaseUsers/admin/user", http.MethodPatch, 200, expected)
+	defer server.Close()
+
+	user, err := atlas.UpdateUser(User{Username: "user", Password: "password"})
+
+	assert.NoError(t, err)
+	assert.Equal(t, &expected, user)
+}
+
+func TestUpdateNonexistentUser(t *testing.T) {
+	atlas, server := setupTest(t, "/databaseUsers/admin/user", http.MethodPatch, 404, errorResponse("USER_NOT_FOUND"))
+	defer server.Close()
+
+	_, err := atlas.UpdateUser(User{Username: "user", Password: "password"})
+
+	assert.EqualError(t, err, ErrUserNotFound.Error())
+}
+
+func TestCreateX509User(t *testing.T) {
+	expected := User{
+		Username:     "user",
+		DatabaseName: "$external",
+		X509Type:     X509TypeManaged,
+	}
+
+	atlas, server := setupTest(t, "/databaseUsers", http.MethodPost, 200, expected)
+	defer server.Close()
+
+	user, err := atlas.CreateUser(User{Username: "user", X509Type: X509TypeManaged})
+
+	assert.NoError(t, err)
+	assert.Equal(t, &expected, user)
+}
+
+func TestCreateUserCertificate(t *testing.T) {
+	expected := "-----BEGIN CERTIFICATE-----\ncert\n-----END CERTIFICATE-----\n"
+
+	atlas, server := setupTest(t, "/databaseUsers/user/certs", http.MethodPost, 201, []byte(expected))
+	defer server.Close()
+
+	certificate, err := atlas.CreateUserCertificate("user", 3)
+
+	assert.NoError(t, err)
+	assert.Equal(t, expected, certificate)
+}
+
+func TestDeleteExternalUser(t *testing.T) {
+	atlas, server := setupTest(t, "/databaseUsers/$external/user", http.MethodDelete, 204, nil)
+	defer server.Close()
+
+	err := atlas.DeleteUser(AuthDatabaseExternal, "user")
+
+	assert.NoError(t, err)
+}
+
+func TestCreateAWSIAMUser(t *testing.T) {
+	expected := User{
+		Username:     "arn:aws:iam::123456789012:role/app",
+		DatabaseName: "$external",
+		AWSIAMType:   AWSIAMTypeRole,
+	}
+
+	atlas, server := setupTest(t, "/databaseUsers", http.MethodPost, 200, expected)
+	defer server.Close()
+
+	user, err := atlas.CreateUser(User{Username: expected.Username, AWSIAMType: AWSIAMTypeRole})
+
+	assert.NoError(t, err)
+	assert.Equal(t, &expected, user)
+}
+
+func TestDeleteAWSIAMUser(t *testing.T) {
+	atlas, server := setupTest(t, "/databaseUsers/$external/arn:aws:iam::123456789012:role%2Fapp", http.MethodDelete, 204, nil)
+	defer server.Close()
+
+	err := atlas.DeleteUser(AuthDatabaseExternal, "arn:aws:iam::123456789012:role/app")
+
+	assert.NoError(t, err)
+}
@@ -0,0 +1,207 @@
+package broker
+
+import (
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/mongodb/mongodb-atlas-service-broker/pkg/atlas"
+	"github.com/pivotal-cf/brokerapi/domain/apiresponses"
+)
+
+// The ways the database user of a binding can authenticate, as passed in the
+// "authentication" parameter.
+const (
+	AuthenticationSCRAM  = "scram"
+	AuthenticationX509   = "x509"
+	AuthenticationAWSIAM = "aws-iam"
+	AuthenticationLDAP   = "ldap"
+)
+
+var authentications = []string{
+	AuthenticationSCRAM,
+	AuthenticationX509,
+	AuthenticationAWSIAM,
+	AuthenticationLDAP,
+}
+
+// certificateValidityMonths is how long client certificates issued for
+// X.509 bindings are valid, which is the Atlas default.
+const certificateValidityMonths = 3
+
+// authenticationFromParams will read how the user of a binding authenticates
+// from the "authentication" parameter. Defaults to AuthenticationSCRAM.
+func authenticationFromParams(rawParams []byte) (string, error) {
+	params := struct {
+		Authentication string `json:"authentication"`
+	}{}
+
+	if len(rawParams) > 0 {
+		err := json.Unmarshal(rawParams, &params)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	if params.Authentication == "" {
+		return AuthenticationSCRAM, nil
+	}
+
+	if !containsString(authentications, params.Authentication) {
+		err := fmt.Errorf("unknown authentication %q, accepted values: %v", params.Authentication, authentications)
+		return "", apiresponses.NewFailureResponse(err, http.StatusBadRequest, "invalid-authentication")
+	}
+
+	return params.Authentication, nil
+}
+
+// awsIAMRoleFromParams will read the ARN of the AWS IAM role used by AWS IAM
+// bindings from the "awsIAMRole" parameter.
+func awsIAMRoleFromParams(rawParams []byte) (string, error) {
+	params := struct {
+		AWSIAMRole string `json:"awsIAMRole"`
+	}{}
+
+	if len(rawParams) > 0 {
+		err := json.Unmarshal(rawParams, &params)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	if !strings.HasPrefix(params.AWSIAMRole, "arn:aws:iam::") || !strings.Contains(params.AWSIAMRole, ":role/") {
+		err := fmt.Errorf(`"awsIAMRole" must be the ARN of an AWS IAM role, e.g. "arn:aws:iam::123456789012:role/app", got %q`, params.AWSIAMRole)
+		return "", apiresponses.NewFailureResponse(err, http.StatusBadRequest, "invalid-aws-iam-role")
+	}
+
+	return params.AWSIAMRole, nil
+}
+
+// ldapUserFromParams will read the distinguished name of the LDAP user or
+// group of an LDAP binding from the "ldapUser" or "ldapGroup" parameter.
+// Returns the name and the matching atlas.LDAPAuthType.
+func ldapUserFromParams(rawParams []byte) (string, string, error) {
+	params := struct {
+		LDAPUser  string `json:"ldapUser"`
+		LDAPGroup string `json:"ldapGroup"`
+	}{}
+
+	if len(rawParams) > 0 {
+		err := json.Unmarshal(rawParams, &params)
+		if err != nil {
+			return "", "", err
+		}
+	}
+
+	if (params.LDAPUser == "") == (params.LDAPGroup == "") {
+		err := errors.New(`LDAP bindings require either "ldapUser" or "ldapGroup" to be set`)
+		return "", "", apiresponses.NewFailureResponse(err, http.StatusBadRequest, "invalid-ldap-user")
+	}
+
+	if params.LDAPGroup != "" {
+		return params.LDAPGroup, atlas.LDAPAuthTypeGroup, nil
+	}
+
+	return params.LDAPUser, atlas.LDAPAuthTypeUser, nil
+}
+
+// checkLDAPConfiguration makes sure LDAP is set up in the project for users
+// of the given atlas.LDAPAuthType. Groups require LDAP authorization.
+func checkLDAPConfiguration(client atlas.Client, authType string) error {
+	config, err := client.GetLDAPConfiguration()
+	if err != nil {
+		return err
+	}
+
+	enabled := config.AuthenticationEnabled != nil && *config.AuthenticationEnabled
+	if authType == atlas.LDAPAuthTypeGroup {
+		enabled = enabled && config.AuthorizationEnabled != nil && *config.AuthorizationEnabled
+	}
+
+	if !enabled {
+		err := fmt.Errorf("LDAP %s bindings require LDAP to be configured in the Atlas project", strings.ToLower(authType))
+		return apiresponses.NewFailureResponse(err, http.StatusUnprocessableEntity, "ldap-not-configured")
+	}
+
+	return nil
+}
+
+// boundUserError explains why creating the user of an AWS IAM role or LDAP
+// user or group failed as it already exists. Atlas has a single database user
+// per role or distinguished name so each can only be bound once. Retries of
+// the same binding still report the binding as already existing.
+func boundUserError(client atlas.Client, user *atlas.User, bindingID string) error {
+	users, err := client.ListUsers()
+	if err != nil {
+		return err
+	}
+
+	for _, existing := range users {
+		if existing.Username == user.Username && labelValue(existing.Labels, LabelBindingID) == bindingID {
+			return atlas.ErrUserAlreadyExists
+		}
+	}
+
+	err = fmt.Errorf("%s is already bound by another binding, each AWS IAM role and LDAP user or group can only be bound once", user.Username)
+	return apiresponses.NewFailureResponse(err, http.StatusUnprocessableEntity, "user-already-bound")
+}
+
+// hasPassword checks if a database user authenticates with a password
+// generated by the broker rather than through $external.
+func hasPassword(user *atlas.User) bool {
+	return user.AuthDatabase() == atlas.AuthDatabaseAdmin
+}
+
+// userDetails will build the connection details for the database user of a
+// binding. The secret is the password of SCRAM users or the PEM encoded
+// certificate and private key of X.509 users. AWS IAM and LDAP users have no
+// secret, LDAP users provide their LDAP password to the driver themselves.
+func userDetails(conn connection, user *atlas.User, secret string, database string) ConnectionDetails {
+	if user.X509Type == atlas.X509TypeManaged {
+		details := conn.externalDetails(authMechanismX509, user.Username, database)
+		details.Certificate, details.PrivateKey = splitPEM(secret)
+		return details
+	}
+
+	if user.AWSIAMType == atlas.AWSIAMTypeRole {
+		return conn.externalDetails(authMechanismAWS, user.Username, database)
+	}
+
+	switch user.LDAPAuthType {
+	case atlas.LDAPAuthTypeUser:
+		return conn.externalDetails(authMechanismPLAIN, user.Username, database)
+	case atlas.LDAPAuthTypeGroup:
+		// Members of the group log in as themselves.
+		details := conn.externalDetails(authMechanismPLAIN, "", database)
+		details.LDAPGroup = user.Username
+		return details
+	}
+
+	return conn.details(user.Username, secret, database)
+}
+
+// splitPEM will split the PEM file Atlas issues for X.509 users into the
+// certificate and the private key.
+func splitPEM(data string) (string, string) {
+	var certificate, privateKey strings.Builder
+
+	rest := []byte(data)
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+
+		if strings.HasSuffix(block.Type, "PRIVATE KEY") {
+			privateKey.Write(pem.EncodeToMemory(block))
+		} else {
+			certificate.Write(pem.EncodeToMemory(block))
+		}
+	}
+
+	return certificate.String(), privateKey.String()
+}
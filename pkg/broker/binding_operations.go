@@ -2,109 +2,235 @@ package broker
 
 import (
 	"context"
-	"crypto/rand"
-	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"net/http"
+	"net/url"
+	"time"
 
 	"github.com/mongodb/mongodb-atlas-service-broker/pkg/atlas"
 	"github.com/pivotal-cf/brokerapi"
+	"github.com/pivotal-cf/brokerapi/domain/apiresponses"
 )
 
-// ConnectionDetails will be returned when a new binding is created.
-type ConnectionDetails struct {
-	Username string `json:"username"`
-	Password string `json:"password"`
-	URI      string `json:"uri"`
-}
-
 // Bind will create a new database user with a username matching the binding ID
-// and a randomly generated password. The user credentials will be returned back.
+// and a randomly generated password or, for X.509 bindings, a client
+// certificate issued by Atlas. AWS IAM and LDAP bindings create a user for
+// the given role, LDAP user, or LDAP group instead. The user credentials will be returned back.
 func (b Broker) Bind(ctx context.Context, instanceID string, bindingID string, details brokerapi.BindDetails, asyncAllowed bool) (spec brokerapi.Binding, err error) {
 	b.logger.Infow("Creating binding", "instance_id", instanceID, "binding_id", bindingID, "details", details)
 
-	client, err := atlasClientFromContext(ctx)
+	client, err := instanceClient(ctx, instanceID)
 	if err != nil {
+		err = atlasToAPIError(err)
 		return
 	}
 
-	// The service_id and plan_id are required to be valid per the specification, despite
-	// not being used for bindings. We look them up to ensure they can be found in the catalog.
-	provider, err := findProviderByServiceID(client, details.ServiceID)
+	// Fetch the instance from Atlas to ensure it exists and retrieve how
+	// applications should connect to it.
+	conn, err := instanceConnection(client, instanceID, details.ServiceID, details.PlanID)
 	if err != nil {
+		b.logger.Errorw("Failed to get existing cluster", "error", err, "instance_id", instanceID)
+		err = atlasToAPIError(err)
 		return
 	}
 
-	_, err = findInstanceSizeByPlanID(provider, details.PlanID)
+	database, err := databaseFromParams(details.RawParameters)
 	if err != nil {
+		b.logger.Errorw("Invalid database in binding parameters", "error", err, "instance_id", instanceID, "binding_id", bindingID, "details", details)
 		return
 	}
 
-	// Fetch the cluster from Atlas to ensure it exists.
-	cluster, err := client.GetCluster(NormalizeClusterName(instanceID))
+	format, err := b.credentialsFormatFromParams(details.RawParameters)
 	if err != nil {
-		b.logger.Errorw("Failed to get existing cluster", "error", err, "instance_id", instanceID)
-		err = atlasToAPIError(err)
+		b.logger.Errorw("Invalid credentials format in binding parameters", "error", err, "instance_id", instanceID, "binding_id", bindingID, "details", details)
 		return
 	}
 
-	// Generate a cryptographically secure random password.
-	password, err := generatePassword()
+	// Binding rotation requests hand the user of the predecessor binding
+	// with a new password to the new binding.
+	if predecessor := predecessorFromContext(ctx); predecessor != "" {
+		var user *atlas.User
+		var secret string
+		user, secret, err = b.rotateUser(client, instanceID, predecessor, bindingID)
+		if err != nil {
+			b.logger.Errorw("Failed to rotate Atlas database user", "error", err, "instance_id", instanceID, "binding_id", bindingID, "predecessor_binding_id", predecessor)
+			err = atlasToAPIError(err)
+			return
+		}
+
+		b.logger.Infow("Successfully rotated Atlas database user", "instance_id", instanceID, "binding_id", bindingID, "predecessor_binding_id", predecessor, "username", user.Username)
+
+		spec = brokerapi.Binding{
+			Credentials: formatCredentials(format, userDetails(conn, user, secret, database)),
+		}
+		return
+	}
+
+	authentication, err := authenticationFromParams(details.RawParameters)
 	if err != nil {
-		b.logger.Errorw("Failed to generate password", "error", err, "instance_id", instanceID, "binding_id", bindingID)
-		err = errors.New("Failed to generate binding password")
+		b.logger.Errorw("Invalid authentication in binding parameters", "error", err, "instance_id", instanceID, "binding_id", bindingID, "details", details)
 		return
 	}
 
-	// Construct a cluster definition from the instance ID, service, plan, and params.
-	user, err := userFromParams(bindingID, password, details.RawParameters)
+	// Generate a password following the configured policy. Users
+	// authenticating through $external don't have a password.
+	var password string
+	if authentication == AuthenticationSCRAM {
+		password, err = b.generatePassword()
+		if err != nil {
+			b.logger.Errorw("Failed to generate password", "error", err, "instance_id", instanceID, "binding_id", bindingID)
+			err = errors.New("Failed to generate binding password")
+			return
+		}
+	}
+
+	// Construct a user definition from the binding ID, database, and params.
+	user, err := b.userFromParams(bindingID, password, database, details.RawParameters)
 	if err != nil {
 		b.logger.Errorw("Couldn't create user from the passed parameters", "error", err, "instance_id", instanceID, "binding_id", bindingID, "details", details)
 		return
 	}
 
+	switch authentication {
+	case AuthenticationX509:
+		user.X509Type = atlas.X509TypeManaged
+	case AuthenticationAWSIAM:
+		// AWS IAM users are named after the role. The label links them to
+		// the binding.
+		user.Username, err = awsIAMRoleFromParams(details.RawParameters)
+		if err != nil {
+			b.logger.Errorw("Invalid AWS IAM role in binding parameters", "error", err, "instance_id", instanceID, "binding_id", bindingID, "details", details)
+			return
+		}
+
+		user.AWSIAMType = atlas.AWSIAMTypeRole
+	case AuthenticationLDAP:
+		// LDAP users and groups are named after their distinguished name.
+		user.Username, user.LDAPAuthType, err = ldapUserFromParams(details.RawParameters)
+		if err != nil {
+			b.logger.Errorw("Invalid LDAP user in binding parameters", "error", err, "instance_id", instanceID, "binding_id", bindingID, "details", details)
+			return
+		}
+
+		err = checkLDAPConfiguration(client, user.LDAPAuthType)
+		if err != nil {
+			b.logger.Errorw("LDAP is not configured in the Atlas project", "error", err, "instance_id", instanceID, "binding_id", bindingID)
+			err = atlasToAPIError(err)
+			return
+		}
+	}
+
+	user.Scopes, err = userScopes(instanceID, details.ServiceID, user.Scopes, details.RawParameters)
+	if err != nil {
+		b.logger.Errorw("Couldn't scope user using the passed parameters", "error", err, "instance_id", instanceID, "binding_id", bindingID, "details", details)
+		return
+	}
+
+	// Atlas deletes users of expiring bindings by itself.
+	ttl, err := ttlFromParams(details.RawParameters)
+	if err != nil {
+		b.logger.Errorw("Invalid ttl in binding parameters", "error", err, "instance_id", instanceID, "binding_id", bindingID, "details", details)
+		return
+	}
+
+	if ttl > 0 {
+		user.DeleteAfterDate = formatExpiry(time.Now().Add(ttl))
+	}
+
+	// The labels track which binding owns the user once it's rotated and
+	// which instance it was bound to.
+	user.Labels = setLabels(user.Labels, []atlas.Label{
+		{Key: LabelBindingID, Value: bindingID},
+		{Key: LabelInstanceID, Value: instanceID},
+	})
+
 	// Create a new Atlas database user from the generated definition.
 	_, err = client.CreateUser(*user)
+	external := user.AWSIAMType == atlas.AWSIAMTypeRole || user.LDAPAuthType == atlas.LDAPAuthTypeUser || user.LDAPAuthType == atlas.LDAPAuthTypeGroup
+	if err == atlas.ErrUserAlreadyExists && external {
+		err = boundUserError(client, user, bindingID)
+	}
+
 	if err != nil {
 		b.logger.Errorw("Failed to create Atlas database user", "error", err, "instance_id", instanceID, "binding_id", bindingID)
 		err = atlasToAPIError(err)
 		return
 	}
 
-	b.logger.Infow("Successfully created Atlas database user", "instance_id", instanceID, "binding_id", bindingID)
+	b.logger.Infow("Successfully created Atlas database user", "instance_id", instanceID, "binding_id", bindingID, "username", user.Username)
+
+	secret := password
+	if user.X509Type == atlas.X509TypeManaged {
+		secret, err = client.CreateUserCertificate(user.Username, certificateValidityMonths)
+		if err != nil {
+			b.logger.Errorw("Failed to create certificate for Atlas database user", "error", err, "instance_id", instanceID, "binding_id", bindingID)
+
+			// Remove the user again as the binding can't be used without
+			// a certificate.
+			if err := client.DeleteUser(atlas.AuthDatabaseExternal, user.Username); err != nil {
+				b.logger.Errorw("Failed to delete Atlas database user without certificate", "error", err, "instance_id", instanceID, "binding_id", bindingID)
+			}
+
+			err = atlasToAPIError(err)
+			return
+		}
+	}
+
+	connectionDetails := userDetails(conn, user, secret, database)
+	connectionDetails.ExpiresAt = user.DeleteAfterDate
 
 	spec = brokerapi.Binding{
-		Credentials: ConnectionDetails{
-			Username: bindingID,
-			Password: password,
-			URI:      cluster.SrvAddress,
-		},
+		Credentials: formatCredentials(format, connectionDetails),
 	}
 	return
 }
 
 // Unbind will delete the database user for a specific binding. The database
-// user should have the binding ID as its username.
+// user has the binding ID as its username or, after a rotation, as its
+// LabelBindingID.
 func (b Broker) Unbind(ctx context.Context, instanceID string, bindingID string, details brokerapi.UnbindDetails, asyncAllowed bool) (spec brokerapi.UnbindSpec, err error) {
 	b.logger.Infow("Releasing binding", "instance_id", instanceID, "binding_id", bindingID, "details", details)
 
-	client, err := atlasClientFromContext(ctx)
+	client, err := instanceClient(ctx, instanceID)
 	if err != nil {
+		err = atlasToAPIError(err)
 		return
 	}
 
-	// Fetch the cluster from Atlas to ensure it exists.
-	_, err = client.GetCluster(NormalizeClusterName(instanceID))
+	// Fetch the instance from Atlas to ensure it exists.
+	if isServerlessService(details.ServiceID) {
+		_, err = client.GetServerlessInstance(NormalizeClusterName(instanceID))
+	} else if isDataFederationService(details.ServiceID) {
+		_, err = client.GetFederatedDatabase(NormalizeClusterName(instanceID))
+	} else {
+		_, err = client.GetCluster(NormalizeClusterName(instanceID))
+	}
+
 	if err != nil {
 		b.logger.Errorw("Failed to get existing cluster", "error", err, "instance_id", instanceID)
 		err = atlasToAPIError(err)
 		return
 	}
 
-	// Delete database user which has the binding ID as its username.
-	err = client.DeleteUser(bindingID)
+	// Find the database user of the binding. After a rotation the user
+	// belongs to the successor binding and is kept.
+	user, err := userForBinding(client, bindingID)
 	if err != nil {
+		b.logger.Errorw("Failed to find Atlas database user", "error", err, "instance_id", instanceID, "binding_id", bindingID)
+		err = atlasToAPIError(err)
+		return
+	}
+
+	if isSuperseded(user, bindingID) {
+		b.logger.Infow("Keeping Atlas database user of rotated binding", "instance_id", instanceID, "binding_id", bindingID, "username", user.Username)
+		return
+	}
+
+	// Atlas might remove an expired user at any time.
+	err = client.DeleteUser(user.DatabaseName, user.Username)
+	if err != nil && err != atlas.ErrUserNotFound {
 		b.logger.Errorw("Failed to delete Atlas database user", "error", err, "instance_id", instanceID, "binding_id", bindingID)
 		err = atlasToAPIError(err)
 		return
@@ -131,26 +257,174 @@ func (b Broker) LastBindingOperation(ctx context.Context, instanceID string, bin
 	panic("not implemented")
 }
 
-// generatePassword will generate a cryptographically secure password.
-// The password will be base64 encoded for easy usage.
-func generatePassword() (string, error) {
-	const numberOfBytes = 32
-	b := make([]byte, numberOfBytes)
+// instanceConnection will fetch the cluster, serverless instance or federated
+// database instance for a binding and return how to connect to it. Instances
+// which don't provide any hosts yet, e.g. while they are still being created,
+// can't be bound to.
+func instanceConnection(client atlas.Client, instanceID string, serviceID string, planID string) (connection, error) {
+	conn, err := findConnection(client, instanceID, serviceID, planID)
+	if err != nil {
+		return connection{}, err
+	}
 
-	_, err := rand.Read(b)
+	if !conn.ready() {
+		err := errors.New("instance not ready, it doesn't provide a connection string yet")
+		return connection{}, apiresponses.NewFailureResponse(err, http.StatusUnprocessableEntity, "instance-not-ready")
+	}
+
+	return conn, nil
+}
+
+// findConnection returns how to connect to the instance of a binding.
+func findConnection(client atlas.Client, instanceID string, serviceID string, planID string) (connection, error) {
+	if isServerlessService(serviceID) {
+		err := validatePlanID(serverlessService, planID)
+		if err != nil {
+			return connection{}, err
+		}
+
+		instance, err := client.GetServerlessInstance(NormalizeClusterName(instanceID))
+		if err != nil {
+			return connection{}, err
+		}
+
+		conn := connection{retryWrites: true}
+		if instance.ConnectionStrings != nil {
+			srvHosts, _ := parseConnectionString(instance.ConnectionStrings.StandardSrv)
+			if len(srvHosts) > 0 {
+				conn.srvHost = srvHosts[0]
+			}
+		}
+
+		return conn, nil
+	}
+
+	if isDataFederationService(serviceID) {
+		err := validatePlanID(dataFederationService, planID)
+		if err != nil {
+			return connection{}, err
+		}
+
+		database, err := client.GetFederatedDatabase(NormalizeClusterName(instanceID))
+		if err != nil {
+			return connection{}, err
+		}
+
+		return federatedDatabaseConnection(database)
+	}
+
+	// The service_id and plan_id are required to be valid per the specification, despite
+	// not being used for bindings. We look them up to ensure they can be found in the catalog.
+	provider, clusterType, err := findProviderByServiceID(client, serviceID)
+	if err != nil {
+		return connection{}, err
+	}
+
+	_, err = findInstanceSizeByPlanID(provider, clusterType, planID)
+	if err != nil {
+		return connection{}, err
+	}
+
+	cluster, err := client.GetCluster(NormalizeClusterName(instanceID))
 	if err != nil {
-		return "", err
+		return connection{}, err
+	}
+
+	// Paused clusters don't accept connections so users couldn't make use of
+	// the binding.
+	if cluster.IsPaused() {
+		err := errors.New(`cluster is paused, resume it by updating the instance with {"cluster": {"paused": false}} before binding`)
+		return connection{}, apiresponses.NewFailureResponse(err, http.StatusUnprocessableEntity, "cluster-paused")
 	}
 
-	return base64.URLEncoding.EncodeToString(b), nil
+	return clusterConnection(cluster), nil
 }
 
-func userFromParams(bindingID string, password string, rawParams []byte) (*atlas.User, error) {
+// clusterConnection returns how to connect to a cluster. The hosts and replica
+// set are taken from the standard connection string, falling back to the
+// mongoURI for responses which don't include it.
+func clusterConnection(cluster *atlas.Cluster) connection {
+	standard := cluster.MongoURI
+	if cluster.ConnectionStrings != nil && cluster.ConnectionStrings.Standard != "" {
+		standard = cluster.ConnectionStrings.Standard
+	}
+
+	hosts, options := parseConnectionString(standard)
+	conn := connection{
+		hosts:       hosts,
+		options:     url.Values{},
+		retryWrites: true,
+	}
+
+	if replicaSet := options.Get("replicaSet"); replicaSet != "" {
+		conn.options.Set("replicaSet", replicaSet)
+	}
+
+	srvHosts, _ := parseConnectionString(cluster.SrvAddress)
+	if len(srvHosts) > 0 {
+		conn.srvHost = srvHosts[0]
+	}
+
+	return conn
+}
+
+// userScopes will determine which instances a binding's user can access.
+// Users are scoped to the bound instance unless the "scopeToInstance"
+// parameter is false, as Atlas users can otherwise access every instance in
+// the project. Scopes passed explicitly may only name the bound instance.
+// Users bound to a federated database instance may only ever access that
+// instance.
+func userScopes(instanceID string, serviceID string, scopes []atlas.Scope, rawParams []byte) ([]atlas.Scope, error) {
+	params := struct {
+		ScopeToInstance *bool `json:"scopeToInstance"`
+	}{}
+
+	if len(rawParams) > 0 {
+		err := json.Unmarshal(rawParams, &params)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if isDataFederationService(serviceID) {
+		return []atlas.Scope{
+			atlas.Scope{
+				Name: NormalizeClusterName(instanceID),
+				Type: atlas.ScopeTypeDataLake,
+			},
+		}, nil
+	}
+
+	for _, scope := range scopes {
+		if scope.Name != NormalizeClusterName(instanceID) {
+			err := fmt.Errorf("scope %s doesn't name the bound instance", scope.Name)
+			return nil, apiresponses.NewFailureResponse(err, http.StatusBadRequest, "invalid-scopes")
+		}
+	}
+
+	scopeToInstance := params.ScopeToInstance == nil || *params.ScopeToInstance
+	if len(scopes) > 0 || !scopeToInstance {
+		return scopes, nil
+	}
+
+	return []atlas.Scope{
+		atlas.Scope{
+			Name: NormalizeClusterName(instanceID),
+			Type: atlas.ScopeTypeCluster,
+		},
+	}, nil
+}
+
+// userFromParams will construct the database user of a binding. Users get
+// the roles passed explicitly, the role preset from the "role" parameter, or
+// the broker's default role policy on the binding's database.
+func (b Broker) userFromParams(bindingID string, password string, database string, rawParams []byte) (*atlas.User, error) {
 	// Set up a params object which will be used for deserialiation.
 	params := struct {
 		User *atlas.User `json:"user"`
+		Role string      `json:"role"`
 	}{
-		&atlas.User{},
+		User: &atlas.User{},
 	}
 
 	// If params were passed we unmarshal them into the params object.
@@ -165,16 +439,11 @@ func userFromParams(bindingID string, password string, rawParams []byte) (*atlas
 	params.User.Username = bindingID
 	params.User.Password = password
 
-	// If no role is specified we default to read/write on any database.
-	// This is the default role when creating a user through the Atlas UI.
-	if len(params.User.Roles) == 0 {
-		params.User.Roles = []atlas.Role{
-			atlas.Role{
-				Name:         "readWriteAnyDatabase",
-				DatabaseName: "admin",
-			},
-		}
+	roles, err := b.userRoles(params.User.Roles, params.Role, database)
+	if err != nil {
+		return nil, err
 	}
 
+	params.User.Roles = roles
 	return params.User, nil
 }
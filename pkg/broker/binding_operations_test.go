@@ -34,8 +34,8 @@ func TestBind(t *testing.T) {
 
 	expectedRoles := []atlas.Role{
 		atlas.Role{
-			Name:         "readWriteAnyDatabase",
-			DatabaseName: "admin",
+			Name:         "readWrite",
+			DatabaseName: DefaultDatabase,
 		},
 	}
 	assert.Equal(t, expectedRoles, user.Roles, "Expected default role to have been assigned")
@@ -54,7 +54,7 @@ func TestBindParams(t *testing.T) {
 		"user": {
 			"ldapAuthType": "NONE",
 			"roles": [{
-				"roleName": "role",
+				"roleName": "read",
 				"databaseName": "database",
 				"collectionName": "collection"
 			}]
@@ -78,7 +78,7 @@ func TestBindParams(t *testing.T) {
 
 	expectedRoles := []atlas.Role{
 		atlas.Role{
-			Name:           "role",
+			Name:           "read",
 			DatabaseName:   "database",
 			CollectionName: "collection",
 		},
@@ -121,6 +121,30 @@ func TestBindMissingInstance(t *testing.T) {
 	assert.EqualError(t, err, apiresponses.ErrInstanceDoesNotExist.Error())
 }
 
+func TestBindPausedCluster(t *testing.T) {
+	broker, client, ctx := setupTest()
+
+	instanceID := "instance"
+	broker.Provision(ctx, instanceID, brokerapi.ProvisionDetails{
+		PlanID:    testPlanID,
+		ServiceID: testServiceID,
+	}, true)
+
+	paused := true
+	client.Clusters[instanceID].Paused = &paused
+
+	bindingID := "binding"
+	_, err := broker.Bind(ctx, instanceID, bindingID, brokerapi.BindDetails{
+		PlanID:    testPlanID,
+		ServiceID: testServiceID,
+	}, true)
+
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "cluster is paused")
+	}
+	assert.Empty(t, client.Users[bindingID], "Expected no user to be created")
+}
+
 func TestUnbind(t *testing.T) {
 	broker, client, ctx := setupTest()
 
@@ -172,3 +196,57 @@ func TestUnbindMissingInstance(t *testing.T) {
 
 	assert.EqualError(t, err, apiresponses.ErrInstanceDoesNotExist.Error())
 }
+
+func TestBindScopes(t *testing.T) {
+	broker, client, ctx := setupTest()
+
+	instanceID := "instance"
+	broker.Provision(ctx, instanceID, brokerapi.ProvisionDetails{
+		PlanID:    testPlanID,
+		ServiceID: testServiceID,
+	}, true)
+
+	bind := func(bindingID string, params string) *atlas.User {
+		_, err := broker.Bind(ctx, instanceID, bindingID, brokerapi.BindDetails{
+			PlanID:        testPlanID,
+			ServiceID:     testServiceID,
+			RawParameters: []byte(params),
+		}, true)
+
+		assert.NoError(t, err)
+		return client.Users[bindingID]
+	}
+
+	// Users are scoped to the bound cluster by default.
+	user := bind("default", `{}`)
+	if assert.NotNil(t, user) {
+		assert.Equal(t, []atlas.Scope{
+			atlas.Scope{Name: instanceID, Type: atlas.ScopeTypeCluster},
+		}, user.Scopes)
+	}
+
+	user = bind("unscoped", `{"scopeToInstance": false}`)
+	if assert.NotNil(t, user) {
+		assert.Empty(t, user.Scopes, "Expected user to access all clusters in the project")
+	}
+
+	user = bind("explicit", `{"user": {"scopes": [{"name": "instance", "type": "CLUSTER"}]}}`)
+	if assert.NotNil(t, user) {
+		assert.Equal(t, []atlas.Scope{
+			atlas.Scope{Name: instanceID, Type: atlas.ScopeTypeCluster},
+		}, user.Scopes)
+	}
+
+	// Explicit scopes can't reach other instances.
+	_, err := broker.Bind(ctx, instanceID, "other", brokerapi.BindDetails{
+		PlanID:        testPlanID,
+		ServiceID:     testServiceID,
+		RawParameters: []byte(`{"user": {"scopes": [{"name": "instance", "type": "CLUSTER"}, {"name": "other", "type": "CLUSTER"}]}}`),
+	}, true)
+
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "doesn't name the bound instance")
+	}
+
+	assert.Nil(t, client.Users["other"])
+}
@@ -22,22 +22,53 @@ var _ brokerapi.ServiceBroker = Broker{}
 type Broker struct {
 	logger    *zap.SugaredLogger
 	whitelist Whitelist
+
+	// mongoDBVersion is the MongoDB major version advertised through the
+	// maintenance_info of every plan. Empty if no version is configured.
+	mongoDBVersion string
+
+	// credentialsFormat is the format binding credentials are returned in
+	// unless a binding selects one.
+	credentialsFormat string
+
+	// defaultRolePolicy is the role bindings get unless they specify roles.
+	defaultRolePolicy string
+
+	// passwordGenerator generates the passwords of binding users. Uses
+	// DefaultPasswordPolicy if not set.
+	passwordGenerator PasswordGenerator
 }
 
-// NewBroker creates a new Broker with a logger.
-func NewBroker(logger *zap.SugaredLogger) *Broker {
-	return &Broker{
-		logger: logger,
+// Option configures optional behaviour of a Broker.
+type Option func(*Broker)
+
+// WithMongoDBVersion configures the MongoDB major version (e.g. "4.2") which
+// new clusters will be created with and existing clusters can be upgraded to
+// using maintenance_info.
+func WithMongoDBVersion(version string) Option {
+	return func(b *Broker) {
+		b.mongoDBVersion = version
 	}
 }
 
+// NewBroker creates a new Broker with a logger.
+func NewBroker(logger *zap.SugaredLogger, options ...Option) *Broker {
+	return NewBrokerWithWhitelist(logger, nil, options...)
+}
+
 // NewBrokerWithWhitelist creates a new Broker with a given logger and a
 // whitelist for allowed providers and their plans.
-func NewBrokerWithWhitelist(logger *zap.SugaredLogger, whitelist Whitelist) *Broker {
-	return &Broker{
+func NewBrokerWithWhitelist(logger *zap.SugaredLogger, whitelist Whitelist, options ...Option) *Broker {
+	broker := &Broker{
 		logger:    logger,
 		whitelist: whitelist,
 	}
+
+	for _, option := range options {
+		option(broker)
+	}
+
+	return broker
 }
 
 // ContextKey represents the key for a value saved in a context. Linter
@@ -55,25 +86,17 @@ var ContextKeyAtlasClient = ContextKey("atlas-client")
 func AuthMiddleware(baseURL string) mux.MiddlewareFunc {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			username, password, ok := r.BasicAuth()
-
 			// The username contains both the group ID and public key
 			// formatted as "<PUBLIC_KEY>@<GROUP_ID>".
-			splitUsername := strings.Split(username, "@")
-
-			// If the credentials are invalid we respond with 401 Unauthorized.
-			// The username needs have the correct format and the password must
-			// not be empty.
-			validUsername := len(splitUsername) == 2
-			validPassword := password != ""
-			if !(ok && validUsername && validPassword) {
+			groupID, publicKey, privateKey, ok := credentialsFromRequest(r)
+			if !ok {
 				w.WriteHeader(http.StatusUnauthorized)
 				return
 			}
 
 			// Create a new client with the extracted API credentials and
 			// attach it to the request context.
-			client := atlas.NewClient(baseURL, splitUsername[1], splitUsername[0], password)
+			client := atlas.NewClient(baseURL, groupID, publicKey, privateKey)
 			ctx := context.WithValue(r.Context(), ContextKeyAtlasClient, client)
 
 			next.ServeHTTP(w, r.WithContext(ctx))
@@ -81,6 +104,50 @@ func AuthMiddleware(baseURL string) mux.MiddlewareFunc {
 	}
 }
 
+// OrgAuthMiddleware is the variant of AuthMiddleware used for
+// project-per-instance mode. The credentials are organization-level API keys
+// with the username formatted as "<PUBLIC_KEY>@<ORG_ID>". The organization
+// client is attached to the request context, both for managing the projects
+// of instances and as the client for requests not tied to an instance.
+func OrgAuthMiddleware(baseURL string) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			orgID, publicKey, privateKey, ok := credentialsFromRequest(r)
+			if !ok {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+
+			ctx := contextWithAtlasCredentials(r.Context(), baseURL, AtlasCredentials{
+				OrgID:      orgID,
+				PublicKey:  publicKey,
+				PrivateKey: privateKey,
+			})
+
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// credentialsFromRequest will parse Atlas API credentials passed using basic
+// auth with the username formatted as "<PUBLIC_KEY>@<ID>". Returns false if
+// the credentials are missing or invalid.
+func credentialsFromRequest(r *http.Request) (id string, publicKey string, privateKey string, ok bool) {
+	username, password, ok := r.BasicAuth()
+
+	splitUsername := strings.Split(username, "@")
+
+	// The username needs have the correct format and the password must not
+	// be empty.
+	validUsername := len(splitUsername) == 2
+	validPassword := password != ""
+	if !(ok && validUsername && validPassword) {
+		return "", "", "", false
+	}
+
+	return splitUsername[1], splitUsername[0], password, true
+}
+
 // atlasClientFromContext will retrieve an Atlas client stored inside the
 // provided context.
 func atlasClientFromContext(ctx context.Context) (atlas.Client, error) {
@@ -95,10 +162,12 @@ func atlasClientFromContext(ctx context.Context) (atlas.Client, error) {
 // atlasToAPIError converts an Atlas error to a OSB response error.
 func atlasToAPIError(err error) error {
 	switch err {
-	case atlas.ErrClusterNotFound:
+	case atlas.ErrClusterNotFound, atlas.ErrServerlessInstanceNotFound, atlas.ErrFederatedDatabaseNotFound, atlas.ErrProjectNotFound:
 		return apiresponses.ErrInstanceDoesNotExist
-	case atlas.ErrClusterAlreadyExists:
+	case atlas.ErrClusterAlreadyExists, atlas.ErrServerlessInstanceAlreadyExists, atlas.ErrFederatedDatabaseAlreadyExists:
 		return apiresponses.ErrInstanceAlreadyExists
+	case atlas.ErrServerlessInstanceDeletionRequested:
+		return apiresponses.ErrConcurrentInstanceAccess
 	case atlas.ErrUserAlreadyExists:
 		return apiresponses.ErrBindingAlreadyExists
 	case atlas.ErrUserNotFound:
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"sort"
 	"strings"
 
 	"github.com/mongodb/mongodb-atlas-service-broker/pkg/atlas"
@@ -27,6 +28,7 @@ var (
 		Name:                 "mongodb-atlas-tenant",
 		Description:          "Atlas cluster hosted on \"TENANT\"",
 		Bindable:             true,
+		Tags:                 serviceTags,
 		InstancesRetrievable: false,
 		BindingsRetrievable:  false,
 		Metadata:             nil,
@@ -44,8 +46,62 @@ var (
 			},
 		},
 	}
+
+	// Serverless instances are offered as a separate service with a single
+	// plan as they have no instance sizes.
+	serverlessService = brokerapi.Service{
+		ID:                   "aosb-cluster-service-serverless",
+		Name:                 "mongodb-atlas-serverless",
+		Description:          "Atlas serverless instance",
+		Bindable:             true,
+		Tags:                 serviceTags,
+		InstancesRetrievable: false,
+		BindingsRetrievable:  false,
+		Metadata:             nil,
+		PlanUpdatable:        false,
+		Plans: []brokerapi.ServicePlan{
+			brokerapi.ServicePlan{
+				ID:          "aosb-cluster-plan-serverless",
+				Name:        "SERVERLESS",
+				Description: "Serverless instance",
+			},
+		},
+	}
+
+	// Federated database instances are offered as a separate service with a
+	// single plan. They are configured entirely through parameters.
+	dataFederationService = brokerapi.Service{
+		ID:                   "aosb-cluster-service-data-federation",
+		Name:                 "mongodb-atlas-data-federation",
+		Description:          "Atlas Data Federation instance",
+		Bindable:             true,
+		Tags:                 serviceTags,
+		InstancesRetrievable: false,
+		BindingsRetrievable:  false,
+		Metadata:             nil,
+		PlanUpdatable:        false,
+		Plans: []brokerapi.ServicePlan{
+			brokerapi.ServicePlan{
+				ID:          "aosb-cluster-plan-data-federation",
+				Name:        "DATA_FEDERATION",
+				Description: "Federated database instance",
+			},
+		},
+	}
+
+	// standaloneServices contains the services which aren't generated from a
+	// provider, keyed by the name used for them in the whitelist. They are not
+	// versioned through maintenance_info.
+	standaloneServices = map[string]brokerapi.Service{
+		atlas.ProviderNameServerless: serverlessService,
+		dataFederationWhitelistName:  dataFederationService,
+	}
 )
 
+// dataFederationWhitelistName is the name used to whitelist the Data
+// Federation service.
+const dataFederationWhitelistName = "DATA_FEDERATION"
+
 // applyWhitelist filters a given service, returning the service with only the
 // whitelisted plans.
 func applyWhitelist(svc brokerapi.Service, whitelistedPlans []string) brokerapi.Service {
@@ -64,6 +120,53 @@ func applyWhitelist(svc brokerapi.Service, whitelistedPlans []string) brokerapi.
 	return whitelistedSvc
 }
 
+// clusterTypes contains the cluster types which are offered as separate
+// services for each dedicated provider.
+var clusterTypes = []string{
+	atlas.ClusterTypeReplicaSet,
+	atlas.ClusterTypeSharded,
+	atlas.ClusterTypeGeoSharded,
+}
+
+// clusterTypeSuffixes maps each cluster type to the suffix used for the names
+// and IDs of its services and plans. Replica sets have no suffix to keep the
+// IDs of the original catalog stable.
+var clusterTypeSuffixes = map[string]string{
+	atlas.ClusterTypeReplicaSet: "",
+	atlas.ClusterTypeSharded:    "sharded",
+	atlas.ClusterTypeGeoSharded: "global",
+}
+
+// clusterTypeDescriptions contains a human-readable prefix for the
+// description of each cluster type's service.
+var clusterTypeDescriptions = map[string]string{
+	atlas.ClusterTypeReplicaSet: "Atlas cluster",
+	atlas.ClusterTypeSharded:    "Sharded Atlas cluster",
+	atlas.ClusterTypeGeoSharded: "Global Atlas cluster",
+}
+
+// standaloneServiceNames returns the whitelist names of all standalone
+// services in alphabetical order.
+func standaloneServiceNames() []string {
+	names := make([]string, 0, len(standaloneServices))
+	for name := range standaloneServices {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+// clusterTypesForProvider returns the cluster types available for a provider.
+// Shared instances can only be deployed as replica sets.
+func clusterTypesForProvider(providerName string) []string {
+	if providerName == "TENANT" {
+		return []string{atlas.ClusterTypeReplicaSet}
+	}
+
+	return clusterTypes
+}
+
 // Services generates the service catalog which will be presented to consumers of the API.
 func (b Broker) Services(ctx context.Context) ([]brokerapi.Service, error) {
 	b.logger.Info("Retrieving service catalog")
@@ -75,9 +178,9 @@ func (b Broker) Services(ctx context.Context) ([]brokerapi.Service, error) {
 	}
 
 	for _, providerName := range providerNames {
-		var svc brokerapi.Service
+		var providerServices []brokerapi.Service
 		if providerName == "TENANT" {
-			svc = sharedService
+			providerServices = []brokerapi.Service{sharedService}
 		} else {
 
 			provider, err := client.GetProvider(providerName)
@@ -85,14 +188,42 @@ func (b Broker) Services(ctx context.Context) ([]brokerapi.Service, error) {
 				return services, err
 			}
 
-			svc = service(provider)
+			for _, clusterType := range clusterTypes {
+				providerServices = append(providerServices, service(provider, clusterType))
+			}
 		}
 
 		whitelistedPlans, isWhitelisted := b.whitelist[providerName]
 		if b.whitelist == nil || isWhitelisted {
-			if isWhitelisted {
-				svc = applyWhitelist(svc, whitelistedPlans)
+			for _, svc := range providerServices {
+				if isWhitelisted {
+					svc = applyWhitelist(svc, whitelistedPlans)
+				}
+
+				// Services are required to have at least one plan.
+				if len(svc.Plans) == 0 {
+					continue
+				}
+
+				services = append(services, b.applyMaintenanceInfo(svc))
 			}
+		}
+	}
+
+	// Standalone services are added after the providers, sorted by name to
+	// keep the catalog stable.
+	for _, name := range standaloneServiceNames() {
+		whitelistedPlans, isWhitelisted := b.whitelist[name]
+		if b.whitelist != nil && !isWhitelisted {
+			continue
+		}
+
+		svc := standaloneServices[name]
+		if isWhitelisted {
+			svc = applyWhitelist(svc, whitelistedPlans)
+		}
+
+		if len(svc.Plans) > 0 {
 			services = append(services, svc)
 		}
 	}
@@ -100,59 +231,92 @@ func (b Broker) Services(ctx context.Context) ([]brokerapi.Service, error) {
 	return services, nil
 }
 
-func service(provider *atlas.Provider) (service brokerapi.Service) {
+func service(provider *atlas.Provider, clusterType string) (service brokerapi.Service) {
 	// Create a CLI-friendly and user-friendly name. Will be displayed in the
 	// marketplace generated by the service catalog.
 	catalogName := fmt.Sprintf("mongodb-atlas-%s", strings.ToLower(provider.Name))
+	if suffix := clusterTypeSuffixes[clusterType]; suffix != "" {
+		catalogName = fmt.Sprintf("%s-%s", catalogName, suffix)
+	}
 
 	service = brokerapi.Service{
-		ID:                   serviceIDForProvider(provider),
+		ID:                   serviceIDForProvider(provider, clusterType),
 		Name:                 catalogName,
-		Description:          fmt.Sprintf(`Atlas cluster hosted on "%s"`, provider.Name),
+		Description:          fmt.Sprintf(`%s hosted on "%s"`, clusterTypeDescriptions[clusterType], provider.Name),
 		Bindable:             true,
+		Tags:                 serviceTags,
 		InstancesRetrievable: false,
 		BindingsRetrievable:  false,
 		Metadata:             nil,
 		PlanUpdatable:        true,
-		Plans:                plansForProvider(provider),
+		Plans:                plansForProvider(provider, clusterType),
 	}
 
 	return service
 }
 
-func findProviderByServiceID(client atlas.Client, serviceID string) (*atlas.Provider, error) {
+// findProviderByServiceID will find the provider and cluster type which a
+// service ID was generated from.
+func findProviderByServiceID(client atlas.Client, serviceID string) (*atlas.Provider, string, error) {
 	for _, providerName := range providerNames {
 		provider, err := client.GetProvider(providerName)
 		if err != nil {
-			return nil, err
+			return nil, "", err
 		}
 
-		if serviceIDForProvider(provider) == serviceID {
-			return provider, nil
+		for _, clusterType := range clusterTypesForProvider(providerName) {
+			if serviceIDForProvider(provider, clusterType) == serviceID {
+				return provider, clusterType, nil
+			}
 		}
 	}
 
-	return nil, apiresponses.NewFailureResponse(errors.New("Invalid service ID"), http.StatusBadRequest, "invalid-service-id")
+	return nil, "", apiresponses.NewFailureResponse(errors.New("Invalid service ID"), http.StatusBadRequest, "invalid-service-id")
 }
 
-func findInstanceSizeByPlanID(provider *atlas.Provider, planID string) (*atlas.InstanceSize, error) {
-	for _, instanceSize := range provider.InstanceSizes {
-		if planIDForInstanceSize(provider, instanceSize) == planID {
-			return &instanceSize, nil
+// findInstanceSizeByPlanID will find the instance size of a plan. Only plans
+// in the catalog are accepted, e.g. sharded clusters need an instance size
+// which supports sharding.
+func findInstanceSizeByPlanID(provider *atlas.Provider, clusterType string, planID string) (*atlas.InstanceSize, error) {
+	for _, plan := range plansForProvider(provider, clusterType) {
+		if plan.ID != planID {
+			continue
+		}
+
+		for _, instanceSize := range provider.InstanceSizes {
+			if instanceSize.Name == plan.Name {
+				return &instanceSize, nil
+			}
 		}
 	}
 
 	return nil, apiresponses.NewFailureResponse(errors.New("Invalid plan ID"), http.StatusBadRequest, "invalid-plan-id")
 }
 
+// validatePlanID makes sure a plan ID belongs to a service with a fixed set of
+// plans.
+func validatePlanID(svc brokerapi.Service, planID string) error {
+	for _, plan := range svc.Plans {
+		if plan.ID == planID {
+			return nil
+		}
+	}
+
+	return apiresponses.NewFailureResponse(errors.New("Invalid plan ID"), http.StatusBadRequest, "invalid-plan-id")
+}
+
 // plansForProvider will convert the available instance sizes for a provider
 // to service plans for the broker.
-func plansForProvider(provider *atlas.Provider) []brokerapi.ServicePlan {
+func plansForProvider(provider *atlas.Provider, clusterType string) []brokerapi.ServicePlan {
 	var plans []brokerapi.ServicePlan
 
 	for _, instanceSize := range provider.InstanceSizes {
+		if clusterType != atlas.ClusterTypeReplicaSet && !supportsSharding(instanceSize) {
+			continue
+		}
+
 		plan := brokerapi.ServicePlan{
-			ID:          planIDForInstanceSize(provider, instanceSize),
+			ID:          planIDForInstanceSize(provider, clusterType, instanceSize),
 			Name:        instanceSize.Name,
 			Description: fmt.Sprintf("Instance size \"%s\"", instanceSize.Name),
 		}
@@ -163,13 +327,35 @@ func plansForProvider(provider *atlas.Provider) []brokerapi.ServicePlan {
 	return plans
 }
 
-// serviceIDForProvider will generate a globally unique ID for a provider.
-func serviceIDForProvider(provider *atlas.Provider) string {
-	return fmt.Sprintf("%s-service-%s", idPrefix, strings.ToLower(provider.Name))
+// supportsSharding checks if clusters of an instance size can be sharded.
+// Atlas requires instance sizes of M30 or larger for sharded clusters.
+func supportsSharding(instanceSize atlas.InstanceSize) bool {
+	switch instanceSize.Name {
+	case "M0", InstanceSizeNameM2, InstanceSizeNameM5, "M10", "M20":
+		return false
+	}
+
+	return true
+}
+
+// serviceIDForProvider will generate a globally unique ID for a provider and
+// cluster type.
+func serviceIDForProvider(provider *atlas.Provider, clusterType string) string {
+	id := fmt.Sprintf("%s-service-%s", idPrefix, strings.ToLower(provider.Name))
+	if suffix := clusterTypeSuffixes[clusterType]; suffix != "" {
+		id = fmt.Sprintf("%s-%s", id, suffix)
+	}
+
+	return id
 }
 
 // planIDForInstanceSize will generate a globally unique ID for an instance size
-// on a specific provider.
-func planIDForInstanceSize(provider *atlas.Provider, instanceSize atlas.InstanceSize) string {
-	return fmt.Sprintf("%s-plan-%s-%s", idPrefix, strings.ToLower(provider.Name), strings.ToLower(instanceSize.Name))
+// on a specific provider and cluster type.
+func planIDForInstanceSize(provider *atlas.Provider, clusterType string, instanceSize atlas.InstanceSize) string {
+	name := strings.ToLower(provider.Name)
+	if suffix := clusterTypeSuffixes[clusterType]; suffix != "" {
+		name = fmt.Sprintf("%s-%s", name, suffix)
+	}
+
+	return fmt.Sprintf("%s-plan-%s-%s", idPrefix, name, strings.ToLower(instanceSize.Name))
 }
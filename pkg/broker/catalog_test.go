@@ -33,3 +33,46 @@ func TestWhitelist(t *testing.T) {
 	assert.Len(t, services[0].Plans, 1)
 	assert.NoError(t, err)
 }
+
+func TestCatalogMaintenanceInfo(t *testing.T) {
+	_, _, ctx := setupTest()
+
+	broker := NewBroker(zap.NewNop().Sugar(), WithMongoDBVersion("4.2"))
+	services, err := broker.Services(ctx)
+
+	assert.NoError(t, err)
+	for _, service := range services {
+		// Standalone services are not versioned through maintenance_info.
+		if isServerlessService(service.ID) || isDataFederationService(service.ID) {
+			continue
+		}
+
+		for _, plan := range service.Plans {
+			if assert.NotNilf(t, plan.MaintenanceInfo, "Expected plan %s to have maintenance_info", plan.Name) {
+				assert.Equal(t, "4.2.0", plan.MaintenanceInfo.Version)
+			}
+		}
+	}
+}
+
+func TestCatalogShardedServices(t *testing.T) {
+	broker, _, ctx := setupTest()
+
+	services, err := broker.Services(ctx)
+	assert.NoError(t, err)
+
+	planIDsByServiceID := map[string][]string{}
+	for _, service := range services {
+		planIDs := []string{}
+		for _, plan := range service.Plans {
+			planIDs = append(planIDs, plan.ID)
+		}
+
+		planIDsByServiceID[service.ID] = planIDs
+	}
+
+	// Sharded and global services should only offer instance sizes which
+	// support sharding.
+	assert.Equal(t, []string{"aosb-cluster-plan-aws-sharded-m30"}, planIDsByServiceID["aosb-cluster-service-aws-sharded"])
+	assert.Equal(t, []string{"aosb-cluster-plan-aws-global-m30"}, planIDsByServiceID["aosb-cluster-service-aws-global"])
+}
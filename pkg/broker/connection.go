@@ -0,0 +1,256 @@
+package broker
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/mongodb/mongodb-atlas-service-broker/pkg/atlas"
+	"github.com/pivotal-cf/brokerapi/domain/apiresponses"
+)
+
+// DefaultDatabase is the database connection strings point to unless a
+// binding specifies one.
+const DefaultDatabase = "test"
+
+// maximumDatabaseNameLength is the maximum length of MongoDB database names.
+const maximumDatabaseNameLength = 64
+
+// ConnectionDetails will be returned when a new binding is created.
+type ConnectionDetails struct {
+	Username string `json:"username"`
+	Password string `json:"password,omitempty"`
+
+	// AuthMechanism is set for users which don't authenticate with SCRAM.
+	AuthMechanism string `json:"authMechanism,omitempty"`
+
+	// Certificate and PrivateKey are the PEM encoded client certificate of
+	// users authenticating with X.509.
+	Certificate string `json:"certificate,omitempty"`
+	PrivateKey  string `json:"privateKey,omitempty"`
+
+	// LDAPGroup is the distinguished name of the LDAP group of a binding.
+	// Members of the group log in as themselves so there is no username.
+	LDAPGroup string `json:"ldapGroup,omitempty"`
+
+	// URI is a ready-to-use connection string including the credentials. It
+	// uses SRV if the instance supports it.
+	URI string `json:"uri"`
+
+	// StandardURI is a connection string listing all hosts for drivers which
+	// don't support SRV.
+	StandardURI string `json:"standardUri,omitempty"`
+
+	Host       string `json:"host"`
+	Port       int    `json:"port,omitempty"`
+	Database   string `json:"database"`
+	ReplicaSet string `json:"replicaSet,omitempty"`
+
+	// ExpiresAt is the time after which Atlas deletes the user of an
+	// expiring binding.
+	ExpiresAt string `json:"expiresAt,omitempty"`
+}
+
+// connection describes how applications connect to an instance. Either the
+// SRV host, the standard hosts, or both are set.
+type connection struct {
+	srvHost string
+	hosts   []string
+
+	// options are the connection string options, such as "replicaSet", taken
+	// from the standard connection string Atlas provides.
+	options url.Values
+
+	// retryWrites is set for instances supporting retryable writes.
+	retryWrites bool
+}
+
+// ready returns whether the connection has any host to connect to.
+func (c connection) ready() bool {
+	return c.srvHost != "" || len(c.hosts) > 0
+}
+
+// parseConnectionString splits a connection string as returned by Atlas into
+// its hosts and options. Empty strings result in no hosts.
+func parseConnectionString(connectionString string) ([]string, url.Values) {
+	rest := connectionString
+	for _, scheme := range []string{"mongodb+srv://", "mongodb://"} {
+		rest = strings.TrimPrefix(rest, scheme)
+	}
+
+	query := ""
+	if i := strings.Index(rest, "?"); i >= 0 {
+		rest, query = rest[:i], rest[i+1:]
+	}
+
+	rest = strings.TrimSuffix(strings.SplitN(rest, "/", 2)[0], "/")
+
+	options, err := url.ParseQuery(query)
+	if err != nil {
+		options = url.Values{}
+	}
+
+	if rest == "" {
+		return nil, options
+	}
+
+	return strings.Split(rest, ","), options
+}
+
+// Authentication mechanisms of users authenticating through $external.
+const (
+	authMechanismX509  = "MONGODB-X509"
+	authMechanismAWS   = "MONGODB-AWS"
+	authMechanismPLAIN = "PLAIN"
+)
+
+// details will build the connection details for a database user.
+// Credentials are escaped and the authentication database, TLS, and retry
+// options are included so applications can use the URIs as they are.
+func (c connection) details(username string, password string, database string) ConnectionDetails {
+	details := c.build(url.UserPassword(username, password), database, url.Values{
+		"authSource": {atlas.AuthDatabaseAdmin},
+	})
+
+	details.Username = username
+	details.Password = password
+	return details
+}
+
+// externalDetails will build the connection details for a database user
+// authenticating with the given mechanism. The server derives X.509 and AWS
+// IAM users from the certificate or the AWS credentials of the application
+// so only PLAIN URIs contain the username, never a password.
+func (c connection) externalDetails(mechanism string, username string, database string) ConnectionDetails {
+	var user *url.Userinfo
+	if mechanism == authMechanismPLAIN && username != "" {
+		user = url.User(username)
+	}
+
+	details := c.build(user, database, url.Values{
+		"authSource":    {atlas.AuthDatabaseExternal},
+		"authMechanism": {mechanism},
+	})
+
+	details.Username = username
+	details.AuthMechanism = mechanism
+	return details
+}
+
+// build will fill in the hosts and URIs of the connection details using the
+// given credentials and authentication options.
+func (c connection) build(user *url.Userinfo, database string, auth url.Values) ConnectionDetails {
+	options := url.Values{}
+	for key, values := range c.options {
+		options[key] = values
+	}
+
+	for key, values := range auth {
+		options[key] = values
+	}
+
+	if c.retryWrites {
+		options.Set("retryWrites", "true")
+		options.Set("w", "majority")
+	}
+
+	details := ConnectionDetails{
+		Database:   database,
+		ReplicaSet: options.Get("replicaSet"),
+	}
+
+	if len(c.hosts) > 0 {
+		// SRV connection strings imply TLS, standard ones need it set.
+		options.Set("ssl", "true")
+		details.StandardURI = connectionURI("mongodb", user, strings.Join(c.hosts, ","), database, options)
+
+		host, port := splitHostPort(c.hosts[0])
+		details.Host = host
+		details.Port = port
+	}
+
+	if c.srvHost == "" {
+		details.URI = details.StandardURI
+		return details
+	}
+
+	// The SRV record provides the replica set and TLS options.
+	options.Del("replicaSet")
+	options.Del("ssl")
+	details.URI = connectionURI("mongodb+srv", user, c.srvHost, database, options)
+
+	if details.Host == "" {
+		details.Host = c.srvHost
+	}
+
+	return details
+}
+
+// connectionURI will build a connection string with escaped credentials.
+func connectionURI(scheme string, user *url.Userinfo, hosts string, database string, options url.Values) string {
+	uri := url.URL{
+		Scheme:   scheme,
+		User:     user,
+		Host:     hosts,
+		Path:     "/" + database,
+		RawQuery: options.Encode(),
+	}
+
+	return uri.String()
+}
+
+// splitHostPort splits a "host:port" pair. The port is 0 if missing.
+func splitHostPort(hostPort string) (string, int) {
+	i := strings.LastIndex(hostPort, ":")
+	if i < 0 {
+		return hostPort, 0
+	}
+
+	port, err := strconv.Atoi(hostPort[i+1:])
+	if err != nil {
+		return hostPort, 0
+	}
+
+	return hostPort[:i], port
+}
+
+// databaseFromParams will read the database a binding connects to from the
+// "database" parameter. Returns DefaultDatabase if not specified.
+func databaseFromParams(rawParams []byte) (string, error) {
+	params := struct {
+		Database string `json:"database"`
+	}{}
+
+	if len(rawParams) > 0 {
+		err := json.Unmarshal(rawParams, &params)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	if params.Database == "" {
+		return DefaultDatabase, nil
+	}
+
+	if err := validateDatabaseName(params.Database); err != nil {
+		return "", apiresponses.NewFailureResponse(err, http.StatusBadRequest, "invalid-database")
+	}
+
+	return params.Database, nil
+}
+
+// validateDatabaseName makes sure a database name is accepted by MongoDB.
+func validateDatabaseName(name string) error {
+	if len(name) > maximumDatabaseNameLength {
+		return fmt.Errorf("database name %q is longer than %d characters", name, maximumDatabaseNameLength)
+	}
+
+	if strings.ContainsAny(name, `/\. "$*<>:|?`) {
+		return fmt.Errorf("database name %q contains invalid characters", name)
+	}
+
+	return nil
+}
@@ -0,0 +1,294 @@
+package broker
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/mongodb/mongodb-atlas-service-broker/pkg/atlas"
+	"go.uber.org/zap"
+)
+
+// Credentials configures broker-held Atlas API keys and the platforms which
+// are allowed to use them. Platforms authenticate with the broker's own
+// credentials so the Atlas API keys never leave the broker.
+type Credentials struct {
+	// Atlas contains the Atlas API keys keyed by a name which platforms
+	// refer to.
+	Atlas map[string]AtlasCredentials `json:"atlas"`
+
+	Platforms []PlatformCredentials `json:"platforms"`
+
+	// Routes choose the Atlas API key based on the context of a request for
+	// platforms which aren't mapped to a single key.
+	Routes []Route `json:"routes"`
+
+	// JWT enables platforms to authenticate using JWTs, e.g. OAuth2 access
+	// tokens, passed as bearer tokens.
+	JWT *JWTConfig `json:"jwt,omitempty"`
+
+	// AtlasBasicAuth keeps accepting Atlas API keys passed using basic auth
+	// the same way AuthMiddleware does, for platforms which aren't
+	// configured.
+	AtlasBasicAuth bool `json:"atlasBasicAuth,omitempty"`
+}
+
+// AtlasCredentials is an Atlas API key for either a single project or, in
+// project-per-instance mode, an organization.
+type AtlasCredentials struct {
+	GroupID    string `json:"groupId,omitempty"`
+	OrgID      string `json:"orgId,omitempty"`
+	PublicKey  string `json:"publicKey"`
+	PrivateKey string `json:"privateKey"`
+}
+
+// PlatformCredentials are the credentials a platform uses to authenticate
+// with the broker. Platforms use either basic auth or a bearer token.
+type PlatformCredentials struct {
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+	Token    string `json:"token,omitempty"`
+
+	// Atlas is the name of the Atlas API key used for the platform's
+	// requests. Requests are routed using the routes if it is empty.
+	Atlas string `json:"atlas,omitempty"`
+
+	// Platform is the platform the credentials belong to, e.g.
+	// "cloudfoundry", and is required if Atlas is empty. Its requests are
+	// only routed using routes for this platform so platforms can't reach
+	// each other's instances.
+	Platform string `json:"platform,omitempty"`
+}
+
+// ReadCredentialsFile will read and validate the credentials in a JSON file,
+// e.g. a mounted secret.
+func ReadCredentialsFile(path string) (*Credentials, error) {
+	bytes, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseCredentials(bytes)
+}
+
+// ParseCredentials will parse and validate credentials in JSON format.
+func ParseCredentials(data []byte) (*Credentials, error) {
+	credentials := &Credentials{}
+	if err := json.Unmarshal(data, credentials); err != nil {
+		return nil, err
+	}
+
+	if err := credentials.validate(); err != nil {
+		return nil, fmt.Errorf("invalid credentials: %s", err)
+	}
+
+	return credentials, nil
+}
+
+// validate makes sure all Atlas API keys are complete and every platform,
+// route, and claim mapping refers to one of them.
+func (c Credentials) validate() error {
+	for name, atlasCredentials := range c.Atlas {
+		if atlasCredentials.PublicKey == "" || atlasCredentials.PrivateKey == "" {
+			return fmt.Errorf("Atlas API key %q requires publicKey and privateKey", name)
+		}
+
+		if (atlasCredentials.GroupID == "") == (atlasCredentials.OrgID == "") {
+			return fmt.Errorf("Atlas API key %q requires either groupId or orgId", name)
+		}
+	}
+
+	if len(c.Platforms) == 0 && c.JWT == nil && !c.AtlasBasicAuth {
+		return fmt.Errorf("at least one platform, jwt, or atlasBasicAuth is required")
+	}
+
+	for i, platform := range c.Platforms {
+		hasBasicAuth := platform.Username != "" && platform.Password != ""
+		hasToken := platform.Token != ""
+		if hasBasicAuth == hasToken {
+			return fmt.Errorf("platform %d requires either username and password or a token", i)
+		}
+
+		if platform.Atlas == "" && len(c.Routes) == 0 {
+			return fmt.Errorf("platform %d requires an Atlas API key or routes", i)
+		}
+
+		if platform.Atlas == "" && platform.Platform == "" {
+			return fmt.Errorf("platform %d requires an Atlas API key or the platform its requests are routed for", i)
+		}
+
+		if _, ok := c.Atlas[platform.Atlas]; platform.Atlas != "" && !ok {
+			return fmt.Errorf("platform %d refers to unknown Atlas API key %q", i, platform.Atlas)
+		}
+	}
+
+	if c.JWT != nil {
+		if err := c.JWT.validate(c.Atlas); err != nil {
+			return fmt.Errorf("jwt: %s", err)
+		}
+
+		if len(c.JWT.Mappings) == 0 {
+			return fmt.Errorf("jwt requires mappings")
+		}
+
+		for i, mapping := range c.JWT.Mappings {
+			if mapping.Platform != "" && len(c.Routes) == 0 {
+				return fmt.Errorf("jwt: mapping %d requires routes", i)
+			}
+		}
+	}
+
+	for i, route := range c.Routes {
+		if err := route.validate(); err != nil {
+			return fmt.Errorf("route %d: %s", i, err)
+		}
+
+		if _, ok := c.Atlas[route.Atlas]; !ok {
+			return fmt.Errorf("route %d refers to unknown Atlas API key %q", i, route.Atlas)
+		}
+	}
+
+	return nil
+}
+
+// authenticate finds the platform a request was made by. Returns false if
+// the request doesn't carry valid platform credentials.
+func (c Credentials) authenticate(r *http.Request) (PlatformCredentials, bool) {
+	username, password, hasBasicAuth := r.BasicAuth()
+
+	token := bearerToken(r)
+
+	for _, platform := range c.Platforms {
+		if platform.Token != "" && token != "" && secureCompare(platform.Token, token) {
+			return platform, true
+		}
+
+		if platform.Username != "" && hasBasicAuth && secureCompare(platform.Username, username) && secureCompare(platform.Password, password) {
+			return platform, true
+		}
+	}
+
+	return PlatformCredentials{}, false
+}
+
+// secureCompare compares two secrets in constant time.
+func secureCompare(a string, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
+
+// bearerToken returns the bearer token of a request or an empty string if
+// there is none.
+func bearerToken(r *http.Request) string {
+	authorization := r.Header.Get("Authorization")
+	if !strings.HasPrefix(authorization, "Bearer ") {
+		return ""
+	}
+
+	return strings.TrimPrefix(authorization, "Bearer ")
+}
+
+// CredentialsMiddleware is an alternative to AuthMiddleware for brokers which
+// hold the Atlas API keys themselves. Platforms authenticate using the
+// broker's credentials or JWTs and requests are made with the Atlas API key
+// they are mapped to, or which the request's context is routed to. Requests
+// from contexts without a route are rejected. Organization API keys enable
+// project-per-instance mode.
+func CredentialsMiddleware(logger *zap.SugaredLogger, baseURL string, credentials *Credentials) mux.MiddlewareFunc {
+	contextRouter := &router{
+		baseURL:     baseURL,
+		credentials: credentials,
+	}
+
+	var verifier *jwtVerifier
+	if credentials.JWT != nil {
+		verifier = newJWTVerifier(*credentials.JWT)
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			platform, ok := credentials.authenticate(r)
+			name := platform.Atlas
+			platformName := platform.Platform
+
+			token := bearerToken(r)
+			switch {
+			case ok:
+			case verifier != nil && token != "":
+				claims, err := verifier.verify(token, time.Now())
+				if err == ErrInsufficientScope {
+					logger.Warnw("Rejected token with insufficient scope", "method", r.Method, "path", r.URL.Path)
+					w.WriteHeader(http.StatusForbidden)
+					return
+				}
+
+				if err != nil {
+					logger.Warnw("Rejected invalid token", "error", err, "method", r.Method, "path", r.URL.Path)
+					w.WriteHeader(http.StatusUnauthorized)
+					return
+				}
+
+				mapping, ok := verifier.mapping(claims)
+				if !ok {
+					logger.Warnw("Rejected token without mapping", "method", r.Method, "path", r.URL.Path)
+					w.WriteHeader(http.StatusForbidden)
+					return
+				}
+
+				name = mapping.Atlas
+				platformName = mapping.Platform
+			case credentials.AtlasBasicAuth:
+				AuthMiddleware(baseURL)(next).ServeHTTP(w, r)
+				return
+			default:
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+
+			if name == "" {
+				var context platformContext
+				var err error
+				name, context, ok, err = contextRouter.route(r, platformName)
+				if err != nil {
+					logger.Errorw("Failed to find the Atlas project of the instance", "error", err, "method", r.Method, "path", r.URL.Path)
+					w.WriteHeader(http.StatusServiceUnavailable)
+					return
+				}
+
+				if !ok {
+					logger.Warnw("Rejected request from unmapped context", "method", r.Method, "path", r.URL.Path, "platform", platformName, "context", context)
+					w.WriteHeader(http.StatusForbidden)
+					return
+				}
+			}
+
+			ctx := contextWithAtlasCredentials(r.Context(), baseURL, credentials.Atlas[name])
+			next.ServeHTTP(w, r.WithContext(ctx))
+
+			// Deprovisioned instances are looked up again if they are
+			// requested once more.
+			vars := mux.Vars(r)
+			if r.Method == http.MethodDelete && vars["instance_id"] != "" && vars["binding_id"] == "" {
+				contextRouter.forget(vars["instance_id"])
+			}
+		})
+	}
+}
+
+// contextWithAtlasCredentials will attach an Atlas client for the API key to
+// the context, the same way AuthMiddleware and OrgAuthMiddleware do.
+func contextWithAtlasCredentials(ctx context.Context, baseURL string, credentials AtlasCredentials) context.Context {
+	if credentials.OrgID != "" {
+		client := atlas.NewOrgClient(baseURL, credentials.OrgID, credentials.PublicKey, credentials.PrivateKey)
+		ctx = context.WithValue(ctx, ContextKeyAtlasOrgClient, client)
+		return context.WithValue(ctx, ContextKeyAtlasClient, client)
+	}
+
+	client := atlas.NewClient(baseURL, credentials.GroupID, credentials.PublicKey, credentials.PrivateKey)
+	return context.WithValue(ctx, ContextKeyAtlasClient, client)
+}
@@ -0,0 +1,105 @@
+package broker
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mongodb/mongodb-atlas-service-broker/pkg/atlas"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+const testCredentials = `{
+	"atlas": {
+		"project": {"groupId": "group-id", "publicKey": "public-key", "privateKey": "private-key"},
+		"org": {"orgId": "org-id", "publicKey": "org-public-key", "privateKey": "org-private-key"}
+	},
+	"platforms": [
+		{"username": "platform", "password": "password", "atlas": "project"},
+		{"token": "token", "atlas": "org"}
+	]
+}`
+
+func TestParseCredentials(t *testing.T) {
+	credentials, err := ParseCredentials([]byte(testCredentials))
+
+	assert.NoError(t, err)
+	assert.Len(t, credentials.Atlas, 2)
+	assert.Len(t, credentials.Platforms, 2)
+}
+
+func TestParseInvalidCredentials(t *testing.T) {
+	invalidCredentials := []string{
+		// No platforms.
+		`{"atlas": {"a": {"groupId": "g", "publicKey": "p", "privateKey": "k"}}}`,
+		// Missing private key.
+		`{"atlas": {"a": {"groupId": "g", "publicKey": "p"}}, "platforms": [{"token": "t", "atlas": "a"}]}`,
+		// Both group and organization.
+		`{"atlas": {"a": {"groupId": "g", "orgId": "o", "publicKey": "p", "privateKey": "k"}}, "platforms": [{"token": "t", "atlas": "a"}]}`,
+		// Unknown Atlas API key.
+		`{"atlas": {"a": {"groupId": "g", "publicKey": "p", "privateKey": "k"}}, "platforms": [{"token": "t", "atlas": "b"}]}`,
+		// Platform without credentials.
+		`{"atlas": {"a": {"groupId": "g", "publicKey": "p", "privateKey": "k"}}, "platforms": [{"username": "u", "atlas": "a"}]}`,
+	}
+
+	for _, credentials := range invalidCredentials {
+		_, err := ParseCredentials([]byte(credentials))
+		assert.Errorf(t, err, "Expected credentials %s to be rejected", credentials)
+	}
+}
+
+func TestCredentialsMiddleware(t *testing.T) {
+	credentials, err := ParseCredentials([]byte(testCredentials))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	middleware := CredentialsMiddleware(zap.NewNop().Sugar(), "http://baseURL", credentials)
+
+	var client *atlas.HTTPClient
+	var orgClient atlas.OrgClient
+	testHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		client, _ = r.Context().Value(ContextKeyAtlasClient).(*atlas.HTTPClient)
+		orgClient, _ = r.Context().Value(ContextKeyAtlasOrgClient).(atlas.OrgClient)
+	})
+
+	serve := func(setup func(r *http.Request)) int {
+		client = nil
+		orgClient = nil
+
+		req, _ := http.NewRequest("GET", "http://test", nil)
+		setup(req)
+
+		w := httptest.NewRecorder()
+		middleware(testHandler).ServeHTTP(w, req)
+		return w.Result().StatusCode
+	}
+
+	// Atlas API keys are no longer accepted.
+	status := serve(func(r *http.Request) { r.SetBasicAuth("public-key@group-id", "private-key") })
+	assert.Equal(t, http.StatusUnauthorized, status)
+
+	status = serve(func(r *http.Request) { r.SetBasicAuth("platform", "wrong") })
+	assert.Equal(t, http.StatusUnauthorized, status)
+
+	status = serve(func(r *http.Request) { r.Header.Set("Authorization", "Bearer wrong") })
+	assert.Equal(t, http.StatusUnauthorized, status)
+
+	// Basic auth is mapped to the project API key.
+	serve(func(r *http.Request) { r.SetBasicAuth("platform", "password") })
+	if assert.NotNil(t, client) {
+		assert.Equal(t, "group-id", client.GroupID)
+		assert.Equal(t, "public-key", client.PublicKey)
+		assert.Equal(t, "private-key", client.PrivateKey)
+	}
+	assert.Nil(t, orgClient)
+
+	// The token is mapped to the organization API key.
+	serve(func(r *http.Request) { r.Header.Set("Authorization", "Bearer token") })
+	if assert.NotNil(t, client) {
+		assert.Equal(t, "org-id", client.OrgID)
+		assert.Equal(t, "org-public-key", client.PublicKey)
+	}
+	assert.NotNil(t, orgClient, "Expected organization API keys to enable project-per-instance mode")
+}
@@ -0,0 +1,183 @@
+package broker
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cronSchedule is a parsed cron expression in the standard five field format
+// "minute hour day-of-month month day-of-week". Every field is stored as a
+// bit set of the values it matches.
+type cronSchedule struct {
+	minutes  uint64
+	hours    uint64
+	days     uint64
+	months   uint64
+	weekdays uint64
+
+	// Cron matches either the day of month or the day of week if both are
+	// restricted, so we need to know which of them were.
+	daysRestricted     bool
+	weekdaysRestricted bool
+}
+
+// cronField describes the allowed range of values of a cron field.
+type cronField struct {
+	name string
+	min  int
+	max  int
+}
+
+var (
+	cronMinute  = cronField{"minute", 0, 59}
+	cronHour    = cronField{"hour", 0, 23}
+	cronDay     = cronField{"day of month", 1, 31}
+	cronMonth   = cronField{"month", 1, 12}
+	cronWeekday = cronField{"day of week", 0, 7}
+)
+
+// cronWildcard matches every value of a field.
+const cronWildcard = "*"
+
+// parseCron will parse a cron expression such as "0 20 * * 1-5". Every field
+// can be a wildcard, a value, a range, or a comma-separated list of those,
+// each optionally followed by a step ("*/15", "8-18/2"). Both 0 and 7 stand
+// for Sunday in the day of week field.
+func parseCron(expression string) (*cronSchedule, error) {
+	fields := strings.Fields(expression)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("cron expression %q must have 5 fields, got %d", expression, len(fields))
+	}
+
+	schedule := &cronSchedule{
+		daysRestricted:     fields[2] != cronWildcard,
+		weekdaysRestricted: fields[4] != cronWildcard,
+	}
+
+	var err error
+	parts := []struct {
+		field cronField
+		value string
+		bits  *uint64
+	}{
+		{cronMinute, fields[0], &schedule.minutes},
+		{cronHour, fields[1], &schedule.hours},
+		{cronDay, fields[2], &schedule.days},
+		{cronMonth, fields[3], &schedule.months},
+		{cronWeekday, fields[4], &schedule.weekdays},
+	}
+
+	for _, part := range parts {
+		*part.bits, err = parseCronField(part.field, part.value)
+		if err != nil {
+			return nil, fmt.Errorf("cron expression %q: %v", expression, err)
+		}
+	}
+
+	// Sunday can be specified as both 0 and 7.
+	if schedule.weekdays&(1<<7) != 0 {
+		schedule.weekdays |= 1
+	}
+
+	return schedule, nil
+}
+
+// parseCronField will parse a single field of a cron expression into a bit
+// set of the values it matches.
+func parseCronField(field cronField, value string) (uint64, error) {
+	var bits uint64
+
+	for _, item := range strings.Split(value, ",") {
+		rangeExpression := item
+		step := 1
+
+		if i := strings.Index(item, "/"); i >= 0 {
+			var err error
+			step, err = strconv.Atoi(item[i+1:])
+			if err != nil || step < 1 {
+				return 0, fmt.Errorf("invalid step %q in %s field", item[i+1:], field.name)
+			}
+
+			rangeExpression = item[:i]
+		}
+
+		start, end := field.min, field.max
+		if rangeExpression != cronWildcard {
+			bounds := strings.SplitN(rangeExpression, "-", 2)
+
+			var err error
+			start, err = parseCronValue(field, bounds[0])
+			if err != nil {
+				return 0, err
+			}
+
+			end = start
+			if len(bounds) == 2 {
+				end, err = parseCronValue(field, bounds[1])
+				if err != nil {
+					return 0, err
+				}
+			} else if step > 1 {
+				// A single value with a step ("5/10") runs until the end of
+				// the range.
+				end = field.max
+			}
+
+			if start > end {
+				return 0, fmt.Errorf("invalid range %q in %s field", rangeExpression, field.name)
+			}
+		}
+
+		for i := start; i <= end; i += step {
+			bits |= 1 << uint(i)
+		}
+	}
+
+	return bits, nil
+}
+
+// parseCronValue will parse a single number of a cron field and make sure it
+// is within the allowed range.
+func parseCronValue(field cronField, value string) (int, error) {
+	number, err := strconv.Atoi(value)
+	if err != nil || number < field.min || number > field.max {
+		return 0, fmt.Errorf("invalid value %q in %s field, must be between %d and %d", value, field.name, field.min, field.max)
+	}
+
+	return number, nil
+}
+
+// matches checks if the schedule fires at the minute of the given time. The
+// time is evaluated in its own location.
+func (c cronSchedule) matches(t time.Time) bool {
+	if c.minutes&(1<<uint(t.Minute())) == 0 ||
+		c.hours&(1<<uint(t.Hour())) == 0 ||
+		c.months&(1<<uint(t.Month())) == 0 {
+		return false
+	}
+
+	dayMatches := c.days&(1<<uint(t.Day())) != 0
+	weekdayMatches := c.weekdays&(1<<uint(t.Weekday())) != 0
+
+	// Like in cron the schedule fires on either day if both are restricted.
+	if c.daysRestricted && c.weekdaysRestricted {
+		return dayMatches || weekdayMatches
+	}
+
+	return dayMatches && weekdayMatches
+}
+
+// lastFiring returns the latest minute in the interval (from, to] at which
+// the schedule fires. Returns false if the schedule doesn't fire in the
+// interval.
+func (c cronSchedule) lastFiring(from time.Time, to time.Time) (time.Time, bool) {
+	for t := to.Truncate(time.Minute); t.After(from); t = t.Add(-time.Minute) {
+		if c.matches(t) {
+			return t, true
+		}
+	}
+
+	return time.Time{}, false
+}
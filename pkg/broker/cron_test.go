@@ -0,0 +1,74 @@
+package broker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCronMatches(t *testing.T) {
+	// Monday, 6 January 2020.
+	monday := time.Date(2020, time.January, 6, 20, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		expression string
+		time       time.Time
+		matches    bool
+	}{
+		{"* * * * *", monday, true},
+		{"0 20 * * 1-5", monday, true},
+		{"0 20 * * 1-5", monday.AddDate(0, 0, 5), false},
+		{"0 20 * * 0,6", monday.AddDate(0, 0, 6), true},
+		{"0 20 * * 7", monday.AddDate(0, 0, 6), true},
+		{"*/15 * * * *", monday.Add(45 * time.Minute), true},
+		{"*/15 * * * *", monday.Add(50 * time.Minute), false},
+		{"0 8-18/2 * * *", monday.Add(-10 * time.Hour), true},
+		{"0 8-18/2 * * *", monday.Add(-11 * time.Hour), false},
+		{"0 20 1 * *", monday, false},
+		// Either day matches if both are restricted.
+		{"0 20 1 * 1", monday, true},
+		{"0 20 6 2 *", monday, false},
+	}
+
+	for _, test := range tests {
+		schedule, err := parseCron(test.expression)
+		if assert.NoError(t, err) {
+			assert.Equalf(t, test.matches, schedule.matches(test.time), "Expected %q matching %s to be %t", test.expression, test.time, test.matches)
+		}
+	}
+}
+
+func TestCronInvalid(t *testing.T) {
+	expressions := []string{
+		"",
+		"* * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"a * * * *",
+	}
+
+	for _, expression := range expressions {
+		_, err := parseCron(expression)
+		assert.Errorf(t, err, "Expected %q to be rejected", expression)
+	}
+}
+
+func TestCronLastFiring(t *testing.T) {
+	schedule, err := parseCron("30 * * * *")
+	assert.NoError(t, err)
+
+	from := time.Date(2020, time.January, 6, 10, 0, 0, 0, time.UTC)
+
+	_, fired := schedule.lastFiring(from, from.Add(29*time.Minute))
+	assert.False(t, fired)
+
+	last, fired := schedule.lastFiring(from, from.Add(3*time.Hour))
+	assert.True(t, fired)
+	assert.Equal(t, from.Add(2*time.Hour+30*time.Minute), last)
+}
@@ -0,0 +1,176 @@
+package broker
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/mongodb/mongodb-atlas-service-broker/pkg/atlas"
+	"github.com/pivotal-cf/brokerapi"
+	"github.com/pivotal-cf/brokerapi/domain/apiresponses"
+)
+
+// isDataFederationService checks if a service ID belongs to the Data
+// Federation service. Requests for the Data Federation service are routed to
+// federated database instances instead of clusters.
+func isDataFederationService(serviceID string) bool {
+	return serviceID == dataFederationService.ID
+}
+
+// provisionDataFederation will create a new federated database instance with
+// the instance ID as its name. Federated database instances are created
+// synchronously.
+func (b Broker) provisionDataFederation(client atlas.Client, instanceID string, details brokerapi.ProvisionDetails) (spec brokerapi.ProvisionedServiceSpec, err error) {
+	err = validatePlanID(dataFederationService, details.PlanID)
+	if err != nil {
+		return
+	}
+
+	database, err := federatedDatabaseFromParams(instanceID, details.RawParameters)
+	if err != nil {
+		b.logger.Errorw("Couldn't create federated database instance from the passed parameters", "error", err, "instance_id", instanceID, "details", details)
+		return
+	}
+
+	resultingDatabase, err := client.CreateFederatedDatabase(*database)
+	if err != nil {
+		b.logger.Errorw("Failed to create Atlas federated database instance", "error", err, "database", database)
+		err = atlasToAPIError(err)
+		return
+	}
+
+	b.logger.Infow("Successfully created Atlas federated database instance", "instance_id", instanceID, "database", resultingDatabase)
+
+	return brokerapi.ProvisionedServiceSpec{
+		DashboardURL: client.GetFederatedDatabaseDashboardURL(),
+	}, nil
+}
+
+// updateDataFederation will replace the configuration of an existing
+// federated database instance synchronously.
+func (b Broker) updateDataFederation(client atlas.Client, instanceID string, details brokerapi.UpdateDetails) (spec brokerapi.UpdateServiceSpec, err error) {
+	if details.PlanID != "" {
+		err = validatePlanID(dataFederationService, details.PlanID)
+		if err != nil {
+			return
+		}
+	}
+
+	database, err := federatedDatabaseFromParams(instanceID, details.RawParameters)
+	if err != nil {
+		return
+	}
+
+	resultingDatabase, err := client.UpdateFederatedDatabase(*database)
+	if err != nil {
+		b.logger.Errorw("Failed to update Atlas federated database instance", "error", err, "database", database)
+		err = atlasToAPIError(err)
+		return
+	}
+
+	b.logger.Infow("Successfully updated Atlas federated database instance", "instance_id", instanceID, "database", resultingDatabase)
+
+	return brokerapi.UpdateServiceSpec{
+		DashboardURL: client.GetFederatedDatabaseDashboardURL(),
+	}, nil
+}
+
+// federatedDatabaseFromParams will construct a federated database instance
+// from an instance ID and raw parameters. The stores and databases of the
+// instance, as well as all other configuration available in the Atlas API,
+// can be passed as "dataFederation". Every store, database, collection and view
+// needs a name and data sources can only read from stores of the instance.
+func federatedDatabaseFromParams(instanceID string, rawParams []byte) (*atlas.FederatedDatabase, error) {
+	// Set up a params object which will be used for deserialiation.
+	params := struct {
+		DataFederation *atlas.FederatedDatabase `json:"dataFederation"`
+	}{
+		&atlas.FederatedDatabase{},
+	}
+
+	// If params were passed we unmarshal them into the params object.
+	if len(rawParams) > 0 {
+		err := json.Unmarshal(rawParams, &params)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Add the instance ID as the name of the instance.
+	params.DataFederation.Name = NormalizeClusterName(instanceID)
+
+	err := validateDataFederationStorage(params.DataFederation.Storage)
+	if err != nil {
+		return nil, invalidDataFederationError(err)
+	}
+
+	return params.DataFederation, nil
+}
+
+// validateDataFederationStorage makes sure the stores and databases of a
+// federated database instance are named and only reference existing stores.
+func validateDataFederationStorage(storage *atlas.DataFederationStorage) error {
+	if storage == nil {
+		return nil
+	}
+
+	stores := map[string]bool{}
+	for i, store := range storage.Stores {
+		if store.Name == "" {
+			return fmt.Errorf("store %d is missing a name", i)
+		}
+
+		if stores[store.Name] {
+			return fmt.Errorf("duplicate store %q", store.Name)
+		}
+		stores[store.Name] = true
+	}
+
+	for i, database := range storage.Databases {
+		if database.Name == "" {
+			return fmt.Errorf("database %d is missing a name", i)
+		}
+
+		for j, collection := range database.Collections {
+			if collection.Name == "" {
+				return fmt.Errorf("collection %d of database %q is missing a name", j, database.Name)
+			}
+
+			for _, source := range collection.DataSources {
+				if !stores[source.StoreName] {
+					return fmt.Errorf("collection %q of database %q references unknown store %q", collection.Name, database.Name, source.StoreName)
+				}
+			}
+		}
+
+		for j, view := range database.Views {
+			if view.Name == "" {
+				return fmt.Errorf("view %d of database %q is missing a name", j, database.Name)
+			}
+		}
+	}
+
+	return nil
+}
+
+func invalidDataFederationError(err error) error {
+	return apiresponses.NewFailureResponse(err, http.StatusBadRequest, "invalid-data-federation")
+}
+
+// federatedDatabaseConnection returns how to connect to a federated database
+// instance using its hostnames. Federated database instances don't provide an
+// SRV address and don't support retryable writes.
+func federatedDatabaseConnection(database *atlas.FederatedDatabase) (connection, error) {
+	if len(database.Hostnames) == 0 {
+		err := errors.New("instance not ready, the federated database instance has no hostnames")
+		return connection{}, apiresponses.NewFailureResponse(err, http.StatusUnprocessableEntity, "instance-not-ready")
+	}
+
+	hosts := make([]string, len(database.Hostnames))
+	for i, hostname := range database.Hostnames {
+		hosts[i] = fmt.Sprintf("%s:27017", hostname)
+	}
+
+	return connection{hosts: hosts}, nil
+}
@@ -0,0 +1,51 @@
+package broker
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/pivotal-cf/brokerapi/domain/apiresponses"
+)
+
+// MaximumBindingTTL is the longest time-to-live of a binding. Atlas only
+// accepts a deleteAfterDate up to a week in the future.
+const MaximumBindingTTL = 7 * 24 * time.Hour
+
+// ttlFromParams will read the time-to-live of a binding from the "ttl"
+// parameter, e.g. "24h". Returns 0 for bindings which don't expire.
+func ttlFromParams(rawParams []byte) (time.Duration, error) {
+	params := struct {
+		TTL string `json:"ttl"`
+	}{}
+
+	if len(rawParams) > 0 {
+		err := json.Unmarshal(rawParams, &params)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	if params.TTL == "" {
+		return 0, nil
+	}
+
+	ttl, err := time.ParseDuration(params.TTL)
+	if err != nil {
+		return 0, apiresponses.NewFailureResponse(err, http.StatusBadRequest, "invalid-ttl")
+	}
+
+	if ttl < time.Minute || ttl > MaximumBindingTTL {
+		err := fmt.Errorf("ttl must be between 1m and %s", MaximumBindingTTL)
+		return 0, apiresponses.NewFailureResponse(err, http.StatusBadRequest, "invalid-ttl")
+	}
+
+	return ttl, nil
+}
+
+// formatExpiry formats the expiry of a binding the way Atlas expects the
+// deleteAfterDate, which is also how it is returned in the credentials.
+func formatExpiry(expiry time.Time) string {
+	return expiry.UTC().Truncate(time.Second).Format(time.RFC3339)
+}
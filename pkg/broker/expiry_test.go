@@ -0,0 +1,77 @@
+package broker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pivotal-cf/brokerapi"
+	"github.com/pivotal-cf/brokerapi/domain/apiresponses"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBindTTL(t *testing.T) {
+	broker, client, ctx := setupTest()
+
+	instanceID := "instance"
+	broker.Provision(ctx, instanceID, brokerapi.ProvisionDetails{
+		PlanID:    testPlanID,
+		ServiceID: testServiceID,
+	}, true)
+
+	spec, err := broker.Bind(ctx, instanceID, "binding", brokerapi.BindDetails{
+		PlanID:        testPlanID,
+		ServiceID:     testServiceID,
+		RawParameters: []byte(`{"ttl": "24h"}`),
+	}, true)
+
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	deleteAfterDate := client.Users["binding"].DeleteAfterDate
+	expiry, err := time.Parse(time.RFC3339, deleteAfterDate)
+	if assert.NoError(t, err) {
+		assert.WithinDuration(t, time.Now().Add(24*time.Hour), expiry, time.Minute)
+	}
+
+	credentials := spec.Credentials.(ConnectionDetails)
+	assert.Equal(t, deleteAfterDate, credentials.ExpiresAt)
+
+	// Atlas removes the user once the binding expired. Platforms treat the
+	// binding being gone as a successful unbind.
+	delete(client.Users, "binding")
+
+	_, err = broker.Unbind(ctx, instanceID, "binding", brokerapi.UnbindDetails{
+		PlanID:    testPlanID,
+		ServiceID: testServiceID,
+	}, true)
+
+	assert.EqualError(t, err, apiresponses.ErrBindingDoesNotExist.Error())
+}
+
+func TestBindInvalidTTL(t *testing.T) {
+	broker, client, ctx := setupTest()
+
+	instanceID := "instance"
+	broker.Provision(ctx, instanceID, brokerapi.ProvisionDetails{
+		PlanID:    testPlanID,
+		ServiceID: testServiceID,
+	}, true)
+
+	invalidParams := []string{
+		`{"ttl": "tomorrow"}`,
+		`{"ttl": "-1h"}`,
+		`{"ttl": "200h"}`,
+	}
+
+	for _, params := range invalidParams {
+		_, err := broker.Bind(ctx, instanceID, "binding", brokerapi.BindDetails{
+			PlanID:        testPlanID,
+			ServiceID:     testServiceID,
+			RawParameters: []byte(params),
+		}, true)
+
+		assert.Errorf(t, err, "Expected params %s to be rejected", params)
+		assert.Nil(t, client.Users["binding"], "Expected no user to be created")
+	}
+}
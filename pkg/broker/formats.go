@@ -0,0 +1,175 @@
+package broker
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/pivotal-cf/brokerapi/domain/apiresponses"
+)
+
+// The formats binding credentials can be returned in. The default format
+// returns ConnectionDetails as they are.
+const (
+	CredentialsFormatDefault = "default"
+
+	// CredentialsFormatSpring adds the Spring Boot properties for Spring Data
+	// MongoDB, i.e. "spring.data.mongodb.uri" and "spring.data.mongodb.database".
+	CredentialsFormatSpring = "spring"
+
+	// CredentialsFormatServiceBinding follows the Kubernetes Service Binding
+	// specification where every entry is a string.
+	CredentialsFormatServiceBinding = "servicebinding"
+)
+
+// The type and provider of bindings as used by the Service Binding
+// specification and binding libraries to detect MongoDB credentials.
+const (
+	bindingType     = "mongodb"
+	bindingProvider = "atlas"
+)
+
+// serviceTags are the tags of all services. Cloud Foundry includes them in
+// VCAP_SERVICES where libraries such as java-cfenv use them to detect
+// MongoDB credentials.
+var serviceTags = []string{"mongodb", "atlas"}
+
+// credentialsFormats contains all supported formats.
+var credentialsFormats = []string{
+	CredentialsFormatDefault,
+	CredentialsFormatSpring,
+	CredentialsFormatServiceBinding,
+}
+
+// IsValidCredentialsFormat checks if a credentials format is supported.
+func IsValidCredentialsFormat(format string) bool {
+	for _, f := range credentialsFormats {
+		if f == format {
+			return true
+		}
+	}
+
+	return false
+}
+
+// WithCredentialsFormat configures the format binding credentials are
+// returned in unless a binding selects one using the "credentialsFormat"
+// parameter.
+func WithCredentialsFormat(format string) Option {
+	return func(b *Broker) {
+		b.credentialsFormat = format
+	}
+}
+
+// credentialsFormatFromParams will read the format from the
+// "credentialsFormat" binding parameter, falling back to the broker's default.
+func (b Broker) credentialsFormatFromParams(rawParams []byte) (string, error) {
+	params := struct {
+		CredentialsFormat string `json:"credentialsFormat"`
+	}{}
+
+	if len(rawParams) > 0 {
+		err := json.Unmarshal(rawParams, &params)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	format := params.CredentialsFormat
+	if format == "" {
+		format = b.credentialsFormat
+	}
+
+	if format == "" {
+		return CredentialsFormatDefault, nil
+	}
+
+	if !IsValidCredentialsFormat(format) {
+		err := fmt.Errorf("unknown credentials format %q, accepted values: %v", format, credentialsFormats)
+		return "", apiresponses.NewFailureResponse(err, http.StatusBadRequest, "invalid-credentials-format")
+	}
+
+	return format, nil
+}
+
+// formatCredentials will convert connection details into the given format.
+func formatCredentials(format string, details ConnectionDetails) interface{} {
+	switch format {
+	case CredentialsFormatSpring:
+		// Spring Boot rejects configurations combining the URI with separate
+		// credentials so only the URI and database are set as Spring
+		// properties. The plain username and password are kept for
+		// applications reading the binding directly.
+		credentials := map[string]interface{}{
+			"type":                         bindingType,
+			"provider":                     bindingProvider,
+			"uri":                          details.URI,
+			"username":                     details.Username,
+			"password":                     details.Password,
+			"database":                     details.Database,
+			"spring.data.mongodb.uri":      details.URI,
+			"spring.data.mongodb.database": details.Database,
+		}
+
+		if details.ExpiresAt != "" {
+			credentials["expiresAt"] = details.ExpiresAt
+		}
+
+		if details.LDAPGroup != "" {
+			credentials["ldapGroup"] = details.LDAPGroup
+		}
+
+		if details.Password == "" {
+			delete(credentials, "password")
+		}
+
+		if details.Certificate != "" {
+			credentials["certificate"] = details.Certificate
+			credentials["privateKey"] = details.PrivateKey
+		}
+
+		return credentials
+	case CredentialsFormatServiceBinding:
+		credentials := map[string]interface{}{
+			"type":     bindingType,
+			"provider": bindingProvider,
+			"uri":      details.URI,
+			"host":     details.Host,
+			"username": details.Username,
+			"password": details.Password,
+			"database": details.Database,
+		}
+
+		if details.Port != 0 {
+			credentials["port"] = strconv.Itoa(details.Port)
+		}
+
+		if details.ReplicaSet != "" {
+			credentials["replicaSet"] = details.ReplicaSet
+		}
+
+		if details.ExpiresAt != "" {
+			credentials["expiresAt"] = details.ExpiresAt
+		}
+
+		if details.LDAPGroup != "" {
+			credentials["ldapGroup"] = details.LDAPGroup
+		}
+
+		if details.Password == "" {
+			delete(credentials, "password")
+		}
+
+		// The Service Binding spec names the client certificate entries
+		// "certificates" and "private-key".
+		if details.Certificate != "" {
+			credentials["certificates"] = details.Certificate
+			credentials["private-key"] = details.PrivateKey
+		}
+
+		return credentials
+	}
+
+	return details
+}
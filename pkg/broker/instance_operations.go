@@ -3,7 +3,10 @@ package broker
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"net/http"
+	"reflect"
 
 	"github.com/mongodb/mongodb-atlas-service-broker/pkg/atlas"
 	"github.com/pivotal-cf/brokerapi"
@@ -17,6 +20,9 @@ const (
 	OperationProvision   = "provision"
 	OperationDeprovision = "deprovision"
 	OperationUpdate      = "update"
+	OperationUpgrade     = "upgrade"
+	OperationPause       = "pause"
+	OperationResume      = "resume"
 	InstanceSizeNameM2   = "M2"
 	InstanceSizeNameM5   = "M5"
 )
@@ -26,17 +32,43 @@ const (
 func (b Broker) Provision(ctx context.Context, instanceID string, details brokerapi.ProvisionDetails, asyncAllowed bool) (spec brokerapi.ProvisionedServiceSpec, err error) {
 	b.logger.Infow("Provisioning instance", "instance_id", instanceID, "details", details)
 
-	client, err := atlasClientFromContext(ctx)
+	// Async needs to be supported for provisioning to work.
+	if !asyncAllowed {
+		err = apiresponses.ErrAsyncRequired
+		return
+	}
+
+	err = b.validateMaintenanceInfo(details.MaintenanceInfo)
 	if err != nil {
 		return
 	}
 
-	// Async needs to be supported for provisioning to work.
-	if !asyncAllowed {
-		err = apiresponses.ErrAsyncRequired
+	// In project-per-instance mode a dedicated project is created for the
+	// instance, which is removed again if the instance can't be created.
+	client, cleanupProject, err := provisionClient(ctx, instanceID)
+	if err != nil {
+		b.logger.Errorw("Failed to set up Atlas project", "error", err, "instance_id", instanceID)
+		err = atlasToAPIError(err)
 		return
 	}
 
+	defer func() {
+		if err != nil {
+			cleanupErr := cleanupProject()
+			if cleanupErr != nil {
+				b.logger.Errorw("Failed to remove Atlas project after failed provisioning", "error", cleanupErr, "instance_id", instanceID)
+			}
+		}
+	}()
+
+	if isServerlessService(details.ServiceID) {
+		return b.provisionServerless(client, instanceID, details)
+	}
+
+	if isDataFederationService(details.ServiceID) {
+		return b.provisionDataFederation(client, instanceID, details)
+	}
+
 	// Construct a cluster definition from the instance ID, service, plan, and params.
 	cluster, err := clusterFromParams(client, instanceID, details.ServiceID, details.PlanID, details.RawParameters)
 	if err != nil {
@@ -44,6 +76,69 @@ func (b Broker) Provision(ctx context.Context, instanceID string, details broker
 		return
 	}
 
+	// Atlas only allows pausing clusters which are already running.
+	if cluster.IsPaused() {
+		err = apiresponses.NewFailureResponse(errors.New("clusters cannot be paused during provisioning"), http.StatusBadRequest, "invalid-cluster")
+		return
+	}
+
+	// New clusters are created with the MongoDB version advertised in the
+	// catalog unless the user explicitly asked for a different version.
+	if cluster.MongoDBMajorVersion == "" {
+		cluster.MongoDBMajorVersion = b.mongoDBVersion
+	}
+
+	applyShardingDefaults(cluster)
+
+	// Schedules are stored as labels which the scheduler picks up.
+	clusterSchedule, err := scheduleFromParams(details.RawParameters)
+	if err != nil {
+		b.logger.Errorw("Couldn't parse schedule from the passed parameters", "error", err, "instance_id", instanceID, "details", details)
+		return
+	}
+
+	if clusterSchedule != nil {
+		if !clusterSchedule.isEmpty() {
+			err = checkSchedulable(cluster)
+			if err != nil {
+				return
+			}
+		}
+
+		cluster.Labels = applyScheduleLabels(cluster.Labels, *clusterSchedule)
+	}
+
+	platform, err := platformContextFromRaw(details.RawContext)
+	if err != nil {
+		b.logger.Errorw("Couldn't parse the platform context", "error", err, "instance_id", instanceID, "details", details)
+		return
+	}
+
+	cluster.Labels = setLabels(cluster.Labels, contextLabels(instanceID, details.ServiceID, details.PlanID, platform))
+
+	// Configuration which can only be applied once the cluster exists is
+	// carried along in the operation data.
+	op, err := operationFromParams(OperationProvision, details.RawParameters)
+	if err != nil {
+		b.logger.Errorw("Couldn't create operation from the passed parameters", "error", err, "instance_id", instanceID, "details", details)
+		return
+	}
+
+	// Labels keep track of the search indexes and online archives created by
+	// the broker so others are left alone during updates.
+	if len(op.SearchIndexes) > 0 {
+		cluster.Labels = trackSearchIndexes(cluster.Labels, replaceManagedSearchIndexes(nil, op.SearchIndexes))
+	}
+
+	if op.OnlineArchives != nil {
+		cluster.Labels = trackOnlineArchives(cluster.Labels, onlineArchiveNamespaces(op.OnlineArchives))
+	}
+
+	operationData, err := op.encode()
+	if err != nil {
+		return
+	}
+
 	// Create a new Atlas cluster from the generated definition
 	resultingCluster, err := client.CreateCluster(*cluster)
 	if err != nil {
@@ -56,7 +151,7 @@ func (b Broker) Provision(ctx context.Context, instanceID string, details broker
 
 	return brokerapi.ProvisionedServiceSpec{
 		IsAsync:       true,
-		OperationData: OperationProvision,
+		OperationData: operationData,
 		DashboardURL:  client.GetDashboardURL(resultingCluster.Name),
 	}, nil
 }
@@ -65,8 +160,9 @@ func (b Broker) Provision(ctx context.Context, instanceID string, details broker
 func (b Broker) Update(ctx context.Context, instanceID string, details brokerapi.UpdateDetails, asyncAllowed bool) (spec brokerapi.UpdateServiceSpec, err error) {
 	b.logger.Infow("Updating instance", "instance_id", instanceID, "details", details)
 
-	client, err := atlasClientFromContext(ctx)
+	client, err := instanceClient(ctx, instanceID)
 	if err != nil {
+		err = atlasToAPIError(err)
 		return
 	}
 
@@ -76,6 +172,24 @@ func (b Broker) Update(ctx context.Context, instanceID string, details brokerapi
 		return
 	}
 
+	err = b.validateMaintenanceInfo(details.MaintenanceInfo)
+	if err != nil {
+		return
+	}
+
+	if isServerlessService(details.ServiceID) {
+		return b.updateServerless(client, instanceID, details)
+	}
+
+	if isDataFederationService(details.ServiceID) {
+		return b.updateDataFederation(client, instanceID, details)
+	}
+
+	// Requests which only change the maintenance_info are version upgrades.
+	if isUpgradeRequest(details) {
+		return b.upgrade(client, instanceID, details)
+	}
+
 	// Fetch the cluster from Atlas. The Atlas API requires an instance size to
 	// be passed during updates (if there are other update to the provider, such
 	// as region). The plan is not included in the OSB call unless it has changed
@@ -92,6 +206,81 @@ func (b Broker) Update(ctx context.Context, instanceID string, details brokerapi
 		return
 	}
 
+	// Atlas replaces all labels on update so the existing ones are the base
+	// for any changes.
+	clusterSchedule, err := scheduleFromParams(details.RawParameters)
+	if err != nil {
+		b.logger.Errorw("Couldn't parse schedule from the passed parameters", "error", err, "instance_id", instanceID, "details", details)
+		return
+	}
+
+	platform, err := platformContextFromRaw(details.RawContext)
+	if err != nil {
+		b.logger.Errorw("Couldn't parse the platform context", "error", err, "instance_id", instanceID, "details", details)
+		return
+	}
+
+	// Pausing and resuming are tracked as their own operations as Atlas
+	// reports the cluster as "IDLE" both before and after the change.
+	operationType := OperationUpdate
+	if cluster.Paused != nil && *cluster.Paused != existingCluster.IsPaused() {
+		operationType = OperationResume
+		if *cluster.Paused {
+			operationType = OperationPause
+		}
+	}
+
+	op, err := operationFromParams(operationType, details.RawParameters)
+	if err != nil {
+		return
+	}
+
+	labels := cluster.Labels
+	if labels == nil {
+		labels = existingCluster.Labels
+	}
+
+	if clusterSchedule != nil {
+		// Clusters keep their instance size unless the plan changes.
+		target := cluster
+		if cluster.ProviderSettings == nil || cluster.ProviderSettings.InstanceSizeName == "" {
+			target = existingCluster
+		}
+
+		if !clusterSchedule.isEmpty() {
+			err = checkSchedulable(target)
+			if err != nil {
+				return
+			}
+		}
+
+		labels = applyScheduleLabels(labels, *clusterSchedule)
+	}
+
+	labels = setLabels(labels, contextLabels(instanceID, details.ServiceID, details.PlanID, platform))
+
+	// The search indexes and online archives created by the broker stay
+	// tracked unless they are replaced.
+	labels = trackSearchIndexes(labels, replaceManagedSearchIndexes(managedSearchIndexes(existingCluster.Labels), op.SearchIndexes))
+
+	managedArchives := managedOnlineArchives(existingCluster.Labels)
+	if op.OnlineArchives != nil {
+		managedArchives = onlineArchiveNamespaces(op.OnlineArchives)
+	}
+
+	labels = trackOnlineArchives(labels, managedArchives)
+
+	// Labels are only sent if they changed to keep requests such as pausing
+	// free of unrelated changes.
+	if !reflect.DeepEqual(labels, existingCluster.Labels) {
+		cluster.Labels = labels
+	}
+
+	operationData, err := op.encode()
+	if err != nil {
+		return
+	}
+
 	// Make sure the cluster provider has all the neccessary params for the
 	// Atlas API. The Atlas API requires both the provider name and instance
 	// size if the provider object is set. If they are missing we use the
@@ -106,6 +295,22 @@ func (b Broker) Update(ctx context.Context, instanceID string, details brokerapi
 		}
 	}
 
+	// Shards and zones are added by passing replication specs. The existing
+	// specs need to be included with their IDs for Atlas to accept the change.
+	if len(cluster.ReplicationSpecs) > 0 {
+		cluster.ReplicationSpecs = mergeReplicationSpecs(existingCluster, cluster.ReplicationSpecs)
+	}
+
+	// The cluster already exists so the rest of the configuration can be
+	// applied right away. It's applied first so the labels tracking the
+	// search indexes and online archives are only written once they exist.
+	err = op.apply(client, existingCluster)
+	if err != nil {
+		b.logger.Errorw("Failed to apply configuration to Atlas cluster", "error", err, "instance_id", instanceID, "operation", op)
+		err = atlasToAPIError(err)
+		return
+	}
+
 	resultingCluster, err := client.UpdateCluster(*cluster)
 	if err != nil {
 		b.logger.Errorw("Failed to update Atlas cluster", "error", err, "cluster", cluster)
@@ -117,7 +322,47 @@ func (b Broker) Update(ctx context.Context, instanceID string, details brokerapi
 
 	return brokerapi.UpdateServiceSpec{
 		IsAsync:       true,
-		OperationData: OperationUpdate,
+		OperationData: operationData,
+		DashboardURL:  client.GetDashboardURL(resultingCluster.Name),
+	}, nil
+}
+
+// upgrade will change the MongoDB major version of an existing Atlas cluster
+// to the version in the maintenance_info asynchronously.
+func (b Broker) upgrade(client atlas.Client, instanceID string, details brokerapi.UpdateDetails) (spec brokerapi.UpdateServiceSpec, err error) {
+	version, err := mongoDBVersionFromMaintenanceInfo(details.MaintenanceInfo)
+	if err != nil {
+		return
+	}
+
+	existingCluster, err := client.GetCluster(NormalizeClusterName(instanceID))
+	if err != nil {
+		err = atlasToAPIError(err)
+		return
+	}
+
+	// Nothing to do if the cluster is already running the requested version.
+	if existingCluster.MongoDBMajorVersion == version {
+		b.logger.Infow("Cluster is already running the requested MongoDB version", "instance_id", instanceID, "version", version)
+		spec.DashboardURL = client.GetDashboardURL(existingCluster.Name)
+		return
+	}
+
+	resultingCluster, err := client.UpdateCluster(atlas.Cluster{
+		Name:                existingCluster.Name,
+		MongoDBMajorVersion: version,
+	})
+	if err != nil {
+		b.logger.Errorw("Failed to upgrade Atlas cluster", "error", err, "instance_id", instanceID, "version", version)
+		err = atlasToAPIError(err)
+		return
+	}
+
+	b.logger.Infow("Successfully started Atlas cluster upgrade process", "instance_id", instanceID, "from_version", existingCluster.MongoDBMajorVersion, "to_version", version)
+
+	return brokerapi.UpdateServiceSpec{
+		IsAsync:       true,
+		OperationData: OperationUpgrade,
 		DashboardURL:  client.GetDashboardURL(resultingCluster.Name),
 	}, nil
 }
@@ -126,8 +371,9 @@ func (b Broker) Update(ctx context.Context, instanceID string, details brokerapi
 func (b Broker) Deprovision(ctx context.Context, instanceID string, details brokerapi.DeprovisionDetails, asyncAllowed bool) (spec brokerapi.DeprovisionServiceSpec, err error) {
 	b.logger.Infow("Deprovisioning instance", "instance_id", instanceID, "details", details)
 
-	client, err := atlasClientFromContext(ctx)
+	client, err := instanceClient(ctx, instanceID)
 	if err != nil {
+		err = atlasToAPIError(err)
 		return
 	}
 
@@ -137,7 +383,39 @@ func (b Broker) Deprovision(ctx context.Context, instanceID string, details brok
 		return
 	}
 
-	err = client.DeleteCluster(NormalizeClusterName(instanceID))
+	// Federated database instances are deleted synchronously.
+	if isDataFederationService(details.ServiceID) {
+		err = client.DeleteFederatedDatabase(NormalizeClusterName(instanceID))
+		if err != nil {
+			b.logger.Errorw("Failed to delete Atlas federated database instance", "error", err, "instance_id", instanceID)
+			err = atlasToAPIError(err)
+			return
+		}
+
+		b.logger.Infow("Successfully deleted Atlas federated database instance", "instance_id", instanceID)
+
+		err = deleteInstanceProject(ctx, instanceID)
+		if err != nil {
+			b.logger.Errorw("Failed to delete Atlas project", "error", err, "instance_id", instanceID)
+			err = atlasToAPIError(err)
+		}
+
+		return
+	}
+
+	op := operation{Type: OperationDeprovision}
+	if isServerlessService(details.ServiceID) {
+		op.Kind = instanceKindServerless
+		err = client.DeleteServerlessInstance(NormalizeClusterName(instanceID))
+
+		// Repeated requests poll the deletion which is still in progress.
+		if err == atlas.ErrServerlessInstanceDeletionRequested {
+			err = nil
+		}
+	} else {
+		err = client.DeleteCluster(NormalizeClusterName(instanceID))
+	}
+
 	if err != nil {
 		b.logger.Errorw("Failed to delete Atlas cluster", "error", err, "instance_id", instanceID)
 		err = atlasToAPIError(err)
@@ -146,9 +424,14 @@ func (b Broker) Deprovision(ctx context.Context, instanceID string, details brok
 
 	b.logger.Infow("Successfully started Atlas cluster deletion process", "instance_id", instanceID)
 
+	operationData, err := op.encode()
+	if err != nil {
+		return
+	}
+
 	return brokerapi.DeprovisionServiceSpec{
 		IsAsync:       true,
-		OperationData: OperationDeprovision,
+		OperationData: operationData,
 	}, nil
 }
 
@@ -165,54 +448,149 @@ func (b Broker) GetInstance(ctx context.Context, instanceID string) (spec broker
 func (b Broker) LastOperation(ctx context.Context, instanceID string, details brokerapi.PollDetails) (resp brokerapi.LastOperation, err error) {
 	b.logger.Infow("Fetching state of last operation", "instance_id", instanceID, "details", details)
 
-	client, err := atlasClientFromContext(ctx)
+	op, err := decodeOperation(details.OperationData)
 	if err != nil {
+		b.logger.Errorw("Failed to decode operation data", "error", err, "instance_id", instanceID, "details", details)
+		err = apiresponses.NewFailureResponse(err, http.StatusBadRequest, "invalid-operation")
 		return
 	}
 
-	cluster, err := client.GetCluster(NormalizeClusterName(instanceID))
-	if err != nil && err != atlas.ErrClusterNotFound {
+	client, err := instanceClient(ctx, instanceID)
+
+	// The project of an instance is removed at the end of deprovisioning.
+	if err == atlas.ErrProjectNotFound && op.Type == OperationDeprovision {
+		return brokerapi.LastOperation{State: brokerapi.Succeeded}, nil
+	}
+
+	if err != nil {
+		err = atlasToAPIError(err)
+		return
+	}
+
+	// Serverless instances go through the same states as clusters. The
+	// service ID is optional when polling so the kind of instance is taken
+	// from the operation data.
+	var stateName string
+	var paused bool
+	if op.Kind == instanceKindServerless || isServerlessService(details.ServiceID) {
+		var instance *atlas.ServerlessInstance
+		instance, err = client.GetServerlessInstance(NormalizeClusterName(instanceID))
+		if err == nil {
+			b.logger.Infow("Found existing serverless instance", "instance", instance)
+			stateName = instance.StateName
+		}
+	} else {
+		var cluster *atlas.Cluster
+		cluster, err = client.GetCluster(NormalizeClusterName(instanceID))
+		if err == nil {
+			b.logger.Infow("Found existing cluster", "cluster", cluster)
+			stateName = cluster.StateName
+			paused = cluster.IsPaused()
+		}
+	}
+
+	// A missing instance is expected once it has been deleted.
+	notFound := err == atlas.ErrClusterNotFound || err == atlas.ErrServerlessInstanceNotFound
+	if err != nil && !notFound {
 		b.logger.Errorw("Failed to get existing cluster", "error", err, "instance_id", instanceID)
 		err = atlasToAPIError(err)
 		return
 	}
 
-	b.logger.Infow("Found existing cluster", "cluster", cluster)
+	state := operationState(op.Type, stateName, paused, notFound)
+
+	// Once the instance is gone its project can be removed as well. Atlas
+	// might not allow it right away so it's retried on the next poll.
+	if state == brokerapi.Succeeded && op.Type == OperationDeprovision {
+		err = deleteInstanceProject(ctx, instanceID)
+		if err != nil {
+			b.logger.Errorw("Failed to delete Atlas project", "error", err, "instance_id", instanceID)
+			return brokerapi.LastOperation{
+				State:       brokerapi.InProgress,
+				Description: "Removing Atlas project",
+			}, nil
+		}
+	}
+
+	// Once Atlas is done with the cluster any pending configuration is
+	// applied and tracked until it has finished as well. Configuration
+	// rejected by Atlas fails the operation as polling again won't help,
+	// while failures to reach Atlas are reported so the platform retries.
+	var description string
+	if state == brokerapi.Succeeded && op.hasPendingWork() {
+		state, description, err = op.finish(client, NormalizeClusterName(instanceID))
+		if atlasErr, ok := err.(*atlas.Error); ok && atlasErr.IsRejected() {
+			b.logger.Errorw("Atlas rejected the configuration of the operation", "error", err, "instance_id", instanceID, "operation", op)
+			return brokerapi.LastOperation{
+				State:       brokerapi.Failed,
+				Description: err.Error(),
+			}, nil
+		}
+
+		if err != nil {
+			b.logger.Errorw("Failed to finish operation", "error", err, "instance_id", instanceID, "operation", op)
+			err = atlasToAPIError(err)
+			return
+		}
+	}
 
+	return brokerapi.LastOperation{
+		State:       state,
+		Description: description,
+	}, nil
+}
+
+// operationState determines the state of an async operation from the state
+// of the instance it was performed on. Paused clusters are reported as "IDLE"
+// and are only treated differently by pause and resume operations.
+func operationState(operation string, stateName string, paused bool, notFound bool) brokerapi.LastOperationState {
 	state := brokerapi.LastOperationState(brokerapi.Failed)
 
-	switch details.OperationData {
+	switch operation {
 	case OperationProvision:
-		switch cluster.StateName {
+		switch stateName {
 		// Provision has succeeded if the cluster is in state "idle".
 		case atlas.ClusterStateIdle:
 			state = brokerapi.Succeeded
-		case atlas.ClusterStateCreating:
+		// Clusters are updated while process arguments are applied after
+		// their creation.
+		case atlas.ClusterStateCreating, atlas.ClusterStateUpdating:
 			state = brokerapi.InProgress
 		}
 	case OperationDeprovision:
 		// The Atlas API may return a 404 response if a cluster is deleted or it
 		// will return the cluster with a state of "DELETED". Both of these
 		// scenarios indicate that a cluster has been successfully deleted.
-		if err == atlas.ErrClusterNotFound || cluster.StateName == atlas.ClusterStateDeleted {
+		if notFound || stateName == atlas.ClusterStateDeleted {
 			state = brokerapi.Succeeded
-		} else if cluster.StateName == atlas.ClusterStateDeleting {
+		} else if stateName == atlas.ClusterStateDeleting {
 			state = brokerapi.InProgress
 		}
-	case OperationUpdate:
+	case OperationUpdate, OperationUpgrade:
 		// We assume that the cluster transitions to the "UPDATING" state
 		// in a synchronous manner during the update request.
-		switch cluster.StateName {
+		switch stateName {
 		case atlas.ClusterStateIdle:
 			state = brokerapi.Succeeded
 		case atlas.ClusterStateUpdating:
 			state = brokerapi.InProgress
 		}
+	case OperationPause, OperationResume:
+		// The operation has only finished once the cluster has reached the
+		// requested state.
+		switch stateName {
+		case atlas.ClusterStateIdle:
+			if paused == (operation == OperationPause) {
+				state = brokerapi.Succeeded
+			} else {
+				state = brokerapi.InProgress
+			}
+		case atlas.ClusterStateUpdating:
+			state = brokerapi.InProgress
+		}
 	}
 
-	return brokerapi.LastOperation{
-		State: state,
-	}, nil
+	return state
 }
 
 // NormalizeClusterName will sanitize a name to make sure it will be accepted
@@ -232,12 +610,15 @@ func NormalizeClusterName(name string) string {
 // clusterFromParams will construct a cluster object from an instance ID,
 // service, plan, and raw parameters. This way users can pass all the
 // configuration available for clusters in the Atlas API as "cluster" in the params.
+// Multi-region clusters can also be described using region templates passed
+// as "regions", which are expanded into the cluster's replication specs.
 func clusterFromParams(client atlas.Client, instanceID string, serviceID string, planID string, rawParams []byte) (*atlas.Cluster, error) {
 	// Set up a params object which will be used for deserialiation.
 	params := struct {
 		Cluster *atlas.Cluster `json:"cluster"`
+		Regions []string       `json:"regions"`
 	}{
-		&atlas.Cluster{},
+		Cluster: &atlas.Cluster{},
 	}
 
 	// If params were passed we unmarshal them into the params object.
@@ -248,6 +629,13 @@ func clusterFromParams(client atlas.Client, instanceID string, serviceID string,
 		}
 	}
 
+	// Regions available for the selected instance size. Regions are only
+	// validated if a plan is specified and Atlas lists regions for the
+	// provider, which it doesn't for M2 and M5 clusters. An instance size
+	// without regions while others have some isn't available anywhere.
+	var availableRegions []atlas.Region
+	validateRegions := false
+
 	// If the plan ID is specified we construct the provider object from the service and plan.
 	// The plan ID is optional during updates but not during creation.
 	if planID != "" {
@@ -257,12 +645,12 @@ func clusterFromParams(client atlas.Client, instanceID string, serviceID string,
 
 		instanceSizeName := params.Cluster.ProviderSettings.InstanceSizeName
 		if instanceSizeName != InstanceSizeNameM2 && instanceSizeName != InstanceSizeNameM5 {
-			provider, err := findProviderByServiceID(client, serviceID)
+			provider, clusterType, err := findProviderByServiceID(client, serviceID)
 			if err != nil {
 				return nil, err
 			}
 
-			instanceSize, err := findInstanceSizeByPlanID(provider, planID)
+			instanceSize, err := findInstanceSizeByPlanID(provider, clusterType, planID)
 			if err != nil {
 				return nil, err
 			}
@@ -270,7 +658,32 @@ func clusterFromParams(client atlas.Client, instanceID string, serviceID string,
 			// Configure provider based on service and plan.
 			params.Cluster.ProviderSettings.ProviderName = provider.Name
 			params.Cluster.ProviderSettings.InstanceSizeName = instanceSize.Name
+			availableRegions = instanceSize.AvailableRegions
+			validateRegions = providerListsRegions(provider)
+
+			// Sharded and global services always deploy their cluster type.
+			// Replica set services leave the type up to the parameters.
+			if clusterType != atlas.ClusterTypeReplicaSet {
+				params.Cluster.ClusterType = clusterType
+			}
+		}
+	}
+
+	if len(params.Regions) > 0 {
+		if len(params.Cluster.ReplicationSpecs) > 0 {
+			return nil, invalidRegionsError(errors.New("regions and replicationSpecs cannot both be specified"))
+		}
+
+		spec, err := replicationSpecFromRegions(params.Regions, availableRegions, validateRegions)
+		if err != nil {
+			return nil, err
 		}
+
+		if params.Cluster.NumShards > 0 {
+			spec.NumShards = params.Cluster.NumShards
+		}
+
+		params.Cluster.ReplicationSpecs = []atlas.ReplicationSpec{*spec}
 	}
 
 	// Add the instance ID as the name of the cluster.
@@ -7,6 +7,7 @@ import (
 	"github.com/pivotal-cf/brokerapi"
 	"github.com/pivotal-cf/brokerapi/domain/apiresponses"
 	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
 )
 
 // TestMissingAsync will make sure all async operations don't accept non-async
@@ -121,7 +122,8 @@ func TestProvisionParams(t *testing.T) {
 	assert.NoError(t, err)
 
 	expected := &atlas.Cluster{
-		StateName: "CREATING",
+		StateName:  "CREATING",
+		SrvAddress: "mongodb+srv://instance.mongodb.net",
 
 		Name:                     instanceID,
 		AutoScaling:              atlas.AutoScalingConfig{DiskGBEnabled: true},
@@ -157,6 +159,11 @@ func TestProvisionParams(t *testing.T) {
 			EncryptEBSVolume: true,
 			VolumeType:       "STANDARD",
 		},
+		Labels: []atlas.Label{
+			atlas.Label{Key: LabelInstanceID, Value: instanceID},
+			atlas.Label{Key: LabelServiceID, Value: testServiceID},
+			atlas.Label{Key: LabelPlanID, Value: testPlanID},
+		},
 	}
 
 	cluster := client.Clusters[instanceID]
@@ -386,3 +393,378 @@ func TestLastOperationDeprovision(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, brokerapi.Succeeded, resp.State)
 }
+
+func TestProvisionMongoDBVersion(t *testing.T) {
+	_, client, ctx := setupTest()
+	broker := NewBroker(zap.NewNop().Sugar(), WithMongoDBVersion("4.2"))
+
+	instanceID := "instance"
+	_, err := broker.Provision(ctx, instanceID, brokerapi.ProvisionDetails{
+		PlanID:          testPlanID,
+		ServiceID:       testServiceID,
+		MaintenanceInfo: brokerapi.MaintenanceInfo{Version: "4.2.0"},
+	}, true)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "4.2", client.Clusters[instanceID].MongoDBMajorVersion)
+
+	// Provisioning with outdated maintenance_info should be rejected.
+	_, err = broker.Provision(ctx, "other-instance", brokerapi.ProvisionDetails{
+		PlanID:          testPlanID,
+		ServiceID:       testServiceID,
+		MaintenanceInfo: brokerapi.MaintenanceInfo{Version: "4.0.0"},
+	}, true)
+
+	assert.EqualError(t, err, apiresponses.ErrMaintenanceInfoConflict.Error())
+}
+
+func TestUpgrade(t *testing.T) {
+	_, client, ctx := setupTest()
+
+	instanceID := "instance"
+	oldBroker := NewBroker(zap.NewNop().Sugar(), WithMongoDBVersion("4.0"))
+	oldBroker.Provision(ctx, instanceID, brokerapi.ProvisionDetails{
+		PlanID:    testPlanID,
+		ServiceID: testServiceID,
+	}, true)
+
+	client.SetClusterState(instanceID, atlas.ClusterStateIdle)
+
+	// Update with only a new maintenance_info after the broker version has
+	// been bumped.
+	broker := NewBroker(zap.NewNop().Sugar(), WithMongoDBVersion("4.2"))
+	res, err := broker.Update(ctx, instanceID, brokerapi.UpdateDetails{
+		PlanID:          testPlanID,
+		ServiceID:       testServiceID,
+		PreviousValues:  brokerapi.PreviousValues{PlanID: testPlanID},
+		MaintenanceInfo: brokerapi.MaintenanceInfo{Version: "4.2.0"},
+	}, true)
+
+	assert.NoError(t, err)
+	assert.True(t, res.IsAsync)
+	assert.Equal(t, OperationUpgrade, res.OperationData)
+
+	cluster := client.Clusters[instanceID]
+	assert.Equal(t, "4.2", cluster.MongoDBMajorVersion)
+	assert.Nil(t, cluster.ProviderSettings, "Expected upgrade to not touch the provider settings")
+
+	client.SetClusterState(instanceID, atlas.ClusterStateUpdating)
+	resp, err := broker.LastOperation(ctx, instanceID, brokerapi.PollDetails{
+		OperationData: OperationUpgrade,
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, brokerapi.InProgress, resp.State)
+
+	client.SetClusterState(instanceID, atlas.ClusterStateIdle)
+	resp, err = broker.LastOperation(ctx, instanceID, brokerapi.PollDetails{
+		OperationData: OperationUpgrade,
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, brokerapi.Succeeded, resp.State)
+}
+
+func TestPauseAndResume(t *testing.T) {
+	broker, client, ctx := setupTest()
+
+	instanceID := "instance"
+	broker.Provision(ctx, instanceID, brokerapi.ProvisionDetails{
+		PlanID:    testPlanID,
+		ServiceID: testServiceID,
+	}, true)
+
+	res, err := broker.Update(ctx, instanceID, brokerapi.UpdateDetails{
+		ServiceID:     testServiceID,
+		RawParameters: []byte(`{"cluster": {"paused": true}}`),
+	}, true)
+
+	assert.NoError(t, err)
+	assert.Equal(t, OperationPause, res.OperationData)
+	assert.True(t, client.Clusters[instanceID].IsPaused())
+
+	client.SetClusterState(instanceID, atlas.ClusterStateIdle)
+	resp, err := broker.LastOperation(ctx, instanceID, brokerapi.PollDetails{
+		OperationData: res.OperationData,
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, brokerapi.Succeeded, resp.State)
+
+	res, err = broker.Update(ctx, instanceID, brokerapi.UpdateDetails{
+		ServiceID:     testServiceID,
+		RawParameters: []byte(`{"cluster": {"paused": false}}`),
+	}, true)
+
+	assert.NoError(t, err)
+	assert.Equal(t, OperationResume, res.OperationData)
+
+	// A cluster which is still reported as paused hasn't resumed yet.
+	paused := true
+	client.Clusters[instanceID].Paused = &paused
+	client.SetClusterState(instanceID, atlas.ClusterStateIdle)
+	resp, err = broker.LastOperation(ctx, instanceID, brokerapi.PollDetails{
+		OperationData: res.OperationData,
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, brokerapi.InProgress, resp.State)
+
+	paused = false
+	resp, err = broker.LastOperation(ctx, instanceID, brokerapi.PollDetails{
+		OperationData: res.OperationData,
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, brokerapi.Succeeded, resp.State)
+}
+
+func TestLastOperationUpdatePaused(t *testing.T) {
+	broker, client, ctx := setupTest()
+
+	instanceID := "instance"
+	broker.Provision(ctx, instanceID, brokerapi.ProvisionDetails{
+		PlanID:    testPlanID,
+		ServiceID: testServiceID,
+	}, true)
+
+	paused := true
+	client.Clusters[instanceID].Paused = &paused
+	client.SetClusterState(instanceID, atlas.ClusterStateIdle)
+
+	resp, err := broker.LastOperation(ctx, instanceID, brokerapi.PollDetails{
+		OperationData: OperationUpdate,
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, brokerapi.Succeeded, resp.State, "Expected idle paused clusters to have finished updating")
+}
+
+func TestProvisionPaused(t *testing.T) {
+	broker, client, ctx := setupTest()
+
+	_, err := broker.Provision(ctx, "instance", brokerapi.ProvisionDetails{
+		PlanID:        testPlanID,
+		ServiceID:     testServiceID,
+		RawParameters: []byte(`{"cluster": {"paused": true}}`),
+	}, true)
+
+	assert.Error(t, err)
+	assert.Len(t, client.Clusters, 0, "Expected no cluster to be created")
+}
+
+func TestUpgradeMaintenanceInfoConflict(t *testing.T) {
+	broker, _, ctx := setupTest()
+
+	instanceID := "instance"
+	broker.Provision(ctx, instanceID, brokerapi.ProvisionDetails{
+		PlanID:    testPlanID,
+		ServiceID: testServiceID,
+	}, true)
+
+	// The broker has no version configured so any maintenance_info conflicts.
+	_, err := broker.Update(ctx, instanceID, brokerapi.UpdateDetails{
+		ServiceID:       testServiceID,
+		MaintenanceInfo: brokerapi.MaintenanceInfo{Version: "4.2.0"},
+	}, true)
+
+	assert.EqualError(t, err, apiresponses.ErrMaintenanceInfoNilConflict.Error())
+}
+
+func TestProvisionSharded(t *testing.T) {
+	broker, client, ctx := setupTest()
+
+	instanceID := "instance"
+	_, err := broker.Provision(ctx, instanceID, brokerapi.ProvisionDetails{
+		PlanID:    "aosb-cluster-plan-aws-sharded-m30",
+		ServiceID: "aosb-cluster-service-aws-sharded",
+	}, true)
+
+	assert.NoError(t, err)
+
+	cluster := client.Clusters[instanceID]
+	assert.Equal(t, atlas.ClusterTypeSharded, cluster.ClusterType)
+	assert.Equal(t, uint(defaultNumShards), cluster.NumShards)
+	assert.Equal(t, "M30", cluster.ProviderSettings.InstanceSizeName)
+}
+
+func TestProvisionShardedUnsupportedInstanceSize(t *testing.T) {
+	broker, client, ctx := setupTest()
+
+	instanceID := "instance"
+	_, err := broker.Provision(ctx, instanceID, brokerapi.ProvisionDetails{
+		PlanID:    "aosb-cluster-plan-aws-sharded-m10",
+		ServiceID: "aosb-cluster-service-aws-sharded",
+	}, true)
+
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "Invalid plan ID")
+	}
+
+	assert.Empty(t, client.Clusters)
+}
+
+func TestProvisionGlobal(t *testing.T) {
+	broker, client, ctx := setupTest()
+
+	params := `{
+		"cluster": {
+			"providerSettings": {
+				"regionName": "EU_WEST_1"
+			}
+		}
+	}`
+
+	instanceID := "instance"
+	_, err := broker.Provision(ctx, instanceID, brokerapi.ProvisionDetails{
+		PlanID:        "aosb-cluster-plan-aws-global-m30",
+		ServiceID:     "aosb-cluster-service-aws-global",
+		RawParameters: []byte(params),
+	}, true)
+
+	assert.NoError(t, err)
+
+	cluster := client.Clusters[instanceID]
+	assert.Equal(t, atlas.ClusterTypeGeoSharded, cluster.ClusterType)
+	assert.Equal(t, []atlas.ReplicationSpec{
+		atlas.ReplicationSpec{
+			NumShards: 1,
+			ZoneName:  defaultZoneName,
+			RegionsConfig: map[string]atlas.RegionsConfig{
+				"EU_WEST_1": atlas.RegionsConfig{
+					ElectableNodes: 3,
+					Priority:       7,
+				},
+			},
+		},
+	}, cluster.ReplicationSpecs)
+}
+
+func TestUpdateGlobalAddZone(t *testing.T) {
+	broker, client, ctx := setupTest()
+
+	instanceID := "instance"
+	broker.Provision(ctx, instanceID, brokerapi.ProvisionDetails{
+		PlanID:    "aosb-cluster-plan-aws-global-m30",
+		ServiceID: "aosb-cluster-service-aws-global",
+	}, true)
+
+	// Simulate Atlas assigning an ID to the existing zone.
+	client.Clusters[instanceID].ReplicationSpecs[0].ID = "zone-1-id"
+
+	params := `{
+		"cluster": {
+			"replicationSpecs": [{
+				"zoneName": "Zone 2",
+				"numShards": 2,
+				"regionsConfig": {
+					"EU_WEST_1": {
+						"electableNodes": 3,
+						"priority": 7
+					}
+				}
+			}]
+		}
+	}`
+
+	_, err := broker.Update(ctx, instanceID, brokerapi.UpdateDetails{
+		ServiceID:     "aosb-cluster-service-aws-global",
+		RawParameters: []byte(params),
+	}, true)
+
+	assert.NoError(t, err)
+
+	specs := client.Clusters[instanceID].ReplicationSpecs
+	if assert.Len(t, specs, 2, "Expected existing zone to be kept") {
+		assert.Equal(t, "Zone 2", specs[0].ZoneName)
+		assert.Empty(t, specs[0].ID)
+		assert.Equal(t, "zone-1-id", specs[1].ID)
+	}
+}
+
+func TestProvisionRegions(t *testing.T) {
+	broker, client, ctx := setupTest()
+
+	params := `{
+		"regions": ["US_EAST_1:3", "US_WEST_2:2", "EU_WEST_1:analytics:1", "US_EAST_1:readOnly:1"]
+	}`
+
+	instanceID := "instance"
+	_, err := broker.Provision(ctx, instanceID, brokerapi.ProvisionDetails{
+		PlanID:        testPlanID,
+		ServiceID:     testServiceID,
+		RawParameters: []byte(params),
+	}, true)
+
+	assert.NoError(t, err)
+
+	expected := []atlas.ReplicationSpec{
+		atlas.ReplicationSpec{
+			NumShards: 1,
+			ZoneName:  defaultZoneName,
+			RegionsConfig: map[string]atlas.RegionsConfig{
+				"US_EAST_1": atlas.RegionsConfig{
+					ElectableNodes: 3,
+					ReadOnlyNodes:  1,
+					Priority:       7,
+				},
+				"US_WEST_2": atlas.RegionsConfig{
+					ElectableNodes: 2,
+					Priority:       6,
+				},
+				"EU_WEST_1": atlas.RegionsConfig{
+					AnalyticsNodes: 1,
+				},
+			},
+		},
+	}
+
+	assert.Equal(t, expected, client.Clusters[instanceID].ReplicationSpecs)
+}
+
+func TestProvisionInvalidRegions(t *testing.T) {
+	broker, client, ctx := setupTest()
+
+	invalidParams := []string{
+		// Even number of electable nodes.
+		`{"regions": ["US_EAST_1:2", "US_WEST_2:2"]}`,
+		// Region not available for the plan.
+		`{"regions": ["AP_SOUTH_1:3"]}`,
+		// Unknown node type.
+		`{"regions": ["US_EAST_1:hidden:3"]}`,
+		// Invalid node count.
+		`{"regions": ["US_EAST_1:three"]}`,
+		// Both regions and replication specs.
+		`{"regions": ["US_EAST_1:3"], "cluster": {"replicationSpecs": [{"numShards": 1}]}}`,
+	}
+
+	for _, params := range invalidParams {
+		_, err := broker.Provision(ctx, "instance", brokerapi.ProvisionDetails{
+			PlanID:        testPlanID,
+			ServiceID:     testServiceID,
+			RawParameters: []byte(params),
+		}, true)
+
+		assert.Errorf(t, err, "Expected params %s to be rejected", params)
+	}
+
+	assert.Len(t, client.Clusters, 0, "Expected no clusters to be created")
+}
+
+func TestProvisionRegionsForInstanceSizeWithoutRegions(t *testing.T) {
+	broker, client, ctx := setupTest()
+
+	// Atlas lists regions for M10 but none for M20, so M20 isn't available
+	// in any of them.
+	_, err := broker.Provision(ctx, "instance", brokerapi.ProvisionDetails{
+		PlanID:        "aosb-cluster-plan-aws-m20",
+		ServiceID:     testServiceID,
+		RawParameters: []byte(`{"regions": ["US_EAST_1:3"]}`),
+	}, true)
+
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "not available for the selected plan")
+	}
+
+	assert.Len(t, client.Clusters, 0, "Expected no clusters to be created")
+}
@@ -0,0 +1,520 @@
+package broker
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	_ "crypto/sha256" // Register the hashes used by the supported algorithms.
+	_ "crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+// jwtLeeway is the clock skew tolerated when checking the validity period of
+// tokens.
+const jwtLeeway = time.Minute
+
+// jwksRefreshInterval limits how often the key set is reloaded when a token
+// is signed with an unknown key, e.g. after the issuer rotated its keys.
+const jwksRefreshInterval = time.Minute
+
+// Errors returned when validating tokens. Tokens which are invalid result in
+// 401 while valid tokens lacking permissions result in 403.
+var (
+	ErrInvalidToken      = errors.New("invalid token")
+	ErrInsufficientScope = errors.New("insufficient scope")
+)
+
+// JWTConfig configures the validation of JWTs, e.g. OAuth2 access tokens
+// obtained using the client credentials flow, which platforms pass as bearer
+// tokens.
+type JWTConfig struct {
+	// Issuer is the expected "iss" claim. Keys are discovered from the
+	// issuer's OpenID configuration unless JWKSFile or JWKSURL is set.
+	Issuer string `json:"issuer"`
+
+	// Audience is the expected "aud" claim.
+	Audience string `json:"audience"`
+
+	// Scopes are all scopes a token requires in its "scope" or "scp" claim.
+	Scopes []string `json:"scopes,omitempty"`
+
+	// JWKSFile is the path to a local JSON Web Key Set.
+	JWKSFile string `json:"jwksFile,omitempty"`
+
+	// JWKSURL is the URL of the issuer's JSON Web Key Set.
+	JWKSURL string `json:"jwksUrl,omitempty"`
+
+	// Mappings choose the Atlas API key based on the claims of a token. The
+	// first matching mapping is used.
+	Mappings []ClaimMapping `json:"mappings,omitempty"`
+}
+
+// ClaimMapping maps tokens with a claim matching a pattern, as accepted by
+// path.Match, to either an Atlas API key or a platform whose routes are used.
+type ClaimMapping struct {
+	Claim string `json:"claim"`
+	Value string `json:"value"`
+
+	// Atlas is the name of the Atlas API key used for matching tokens.
+	Atlas string `json:"atlas,omitempty"`
+
+	// Platform is the platform, e.g. "kubernetes", whose routes are used for
+	// matching tokens if Atlas is empty.
+	Platform string `json:"platform,omitempty"`
+}
+
+// matches checks if the claim of the mapping matches the pattern. Claims
+// containing a list, such as "aud" or "groups", match if any element does.
+func (m ClaimMapping) matches(claims jwtClaims) bool {
+	for _, value := range claims.strings(m.Claim) {
+		if matched, _ := path.Match(m.Value, value); matched {
+			return true
+		}
+	}
+
+	return false
+}
+
+// validate makes sure the configuration is complete and all mappings refer to
+// one of the Atlas API keys.
+func (c JWTConfig) validate(atlasCredentials map[string]AtlasCredentials) error {
+	if c.Issuer == "" || c.Audience == "" {
+		return fmt.Errorf("issuer and audience are required")
+	}
+
+	if c.JWKSFile != "" && c.JWKSURL != "" {
+		return fmt.Errorf("only one of jwksFile and jwksUrl can be set")
+	}
+
+	for i, mapping := range c.Mappings {
+		if mapping.Claim == "" {
+			return fmt.Errorf("mapping %d requires a claim", i)
+		}
+
+		if _, err := path.Match(mapping.Value, ""); err != nil {
+			return fmt.Errorf("mapping %d has invalid pattern %q", i, mapping.Value)
+		}
+
+		if (mapping.Atlas == "") == (mapping.Platform == "") {
+			return fmt.Errorf("mapping %d requires either atlas or platform", i)
+		}
+
+		if _, ok := atlasCredentials[mapping.Atlas]; mapping.Atlas != "" && !ok {
+			return fmt.Errorf("mapping %d refers to unknown Atlas API key %q", i, mapping.Atlas)
+		}
+	}
+
+	return nil
+}
+
+// jwtClaims are the claims of a verified token.
+type jwtClaims map[string]interface{}
+
+// strings returns the values of a claim which is either a single string or a
+// list of strings.
+func (c jwtClaims) strings(name string) []string {
+	switch value := c[name].(type) {
+	case string:
+		return []string{value}
+	case []interface{}:
+		values := []string{}
+		for _, element := range value {
+			if s, ok := element.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	}
+
+	return nil
+}
+
+// time returns the value of a numeric date claim such as "exp".
+func (c jwtClaims) time(name string) (time.Time, bool) {
+	value, ok := c[name].(float64)
+	if !ok {
+		return time.Time{}, false
+	}
+
+	return time.Unix(int64(value), 0), true
+}
+
+// scopes returns the scopes granted by the token, either as a space-separated
+// "scope" claim or a "scp" list.
+func (c jwtClaims) scopes() []string {
+	if scope, ok := c["scope"].(string); ok {
+		return strings.Fields(scope)
+	}
+
+	return c.strings("scp")
+}
+
+// jwtVerifier verifies tokens against the configuration and the issuer's
+// keys.
+type jwtVerifier struct {
+	config JWTConfig
+	keys   *jwks
+}
+
+// newJWTVerifier creates a verifier loading keys from the configured source.
+func newJWTVerifier(config JWTConfig) *jwtVerifier {
+	return &jwtVerifier{
+		config: config,
+		keys:   &jwks{config: config},
+	}
+}
+
+// verify will check the signature and claims of a token. Returns
+// ErrInvalidToken if the token can't be trusted and ErrInsufficientScope if
+// it lacks one of the required scopes.
+func (v *jwtVerifier) verify(token string, now time.Time) (jwtClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrInvalidToken
+	}
+
+	var header struct {
+		Algorithm string `json:"alg"`
+		KeyID     string `json:"kid"`
+	}
+
+	if err := decodeJWTPart(parts[0], &header); err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	key, err := v.keys.key(header.KeyID, now)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := verifySignature(header.Algorithm, key, []byte(parts[0]+"."+parts[1]), signature); err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	claims := jwtClaims{}
+	if err := decodeJWTPart(parts[1], &claims); err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	if issuer, _ := claims["iss"].(string); issuer != v.config.Issuer {
+		return nil, ErrInvalidToken
+	}
+
+	if !containsString(claims.strings("aud"), v.config.Audience) {
+		return nil, ErrInvalidToken
+	}
+
+	expiry, ok := claims.time("exp")
+	if !ok || now.After(expiry.Add(jwtLeeway)) {
+		return nil, ErrInvalidToken
+	}
+
+	if notBefore, ok := claims.time("nbf"); ok && now.Add(jwtLeeway).Before(notBefore) {
+		return nil, ErrInvalidToken
+	}
+
+	scopes := claims.scopes()
+	for _, scope := range v.config.Scopes {
+		if !containsString(scopes, scope) {
+			return nil, ErrInsufficientScope
+		}
+	}
+
+	return claims, nil
+}
+
+// mapping returns the first mapping matching the claims. Returns false if no
+// mapping matches.
+func (v *jwtVerifier) mapping(claims jwtClaims) (ClaimMapping, bool) {
+	for _, mapping := range v.config.Mappings {
+		if mapping.matches(claims) {
+			return mapping, true
+		}
+	}
+
+	return ClaimMapping{}, false
+}
+
+// decodeJWTPart will decode a base64url encoded JSON part of a token.
+func decodeJWTPart(part string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(data, v)
+}
+
+// verifySignature checks the signature of a token. Only asymmetric algorithms
+// are supported as the broker never shares a secret with the issuer.
+func verifySignature(algorithm string, key crypto.PublicKey, signed []byte, signature []byte) error {
+	hashes := map[string]crypto.Hash{
+		"RS256": crypto.SHA256, "RS384": crypto.SHA384, "RS512": crypto.SHA512,
+		"ES256": crypto.SHA256, "ES384": crypto.SHA384, "ES512": crypto.SHA512,
+	}
+
+	hash, ok := hashes[algorithm]
+	if !ok {
+		return fmt.Errorf("unsupported algorithm %q", algorithm)
+	}
+
+	hasher := hash.New()
+	hasher.Write(signed)
+	digest := hasher.Sum(nil)
+
+	switch key := key.(type) {
+	case *rsa.PublicKey:
+		if !strings.HasPrefix(algorithm, "RS") {
+			break
+		}
+		return rsa.VerifyPKCS1v15(key, hash, digest, signature)
+	case *ecdsa.PublicKey:
+		size := (key.Curve.Params().BitSize + 7) / 8
+		if !strings.HasPrefix(algorithm, "ES") || len(signature) != 2*size {
+			break
+		}
+
+		r := new(big.Int).SetBytes(signature[:size])
+		s := new(big.Int).SetBytes(signature[size:])
+		if ecdsa.Verify(key, digest, r, s) {
+			return nil
+		}
+		return errors.New("invalid signature")
+	}
+
+	return fmt.Errorf("key does not match algorithm %q", algorithm)
+}
+
+// jwks is a JSON Web Key Set which is loaded on first use and reloaded when a
+// token refers to an unknown key.
+type jwks struct {
+	config JWTConfig
+
+	mutex sync.Mutex
+	keys  map[string]crypto.PublicKey
+
+	// loadedAt is the time of the last attempt to load the set and loadErr
+	// its error, if any. Failed attempts are limited like successful ones.
+	loadedAt time.Time
+	loadErr  error
+
+	// loading is closed once the load in progress, if any, has finished.
+	loading chan struct{}
+}
+
+// key returns the public key with the given ID. Tokens without a key ID are
+// accepted if the set contains a single key.
+func (s *jwks) key(keyID string, now time.Time) (crypto.PublicKey, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if key, ok := s.find(keyID); ok {
+		return key, nil
+	}
+
+	// Requests arriving during a load wait for it instead of starting
+	// another one.
+	if loading := s.loading; loading != nil {
+		s.mutex.Unlock()
+		<-loading
+		s.mutex.Lock()
+	} else if s.loadedAt.IsZero() || now.Sub(s.loadedAt) >= jwksRefreshInterval {
+		s.reload(now)
+	}
+
+	if key, ok := s.find(keyID); ok {
+		return key, nil
+	}
+
+	if s.loadErr != nil {
+		return nil, fmt.Errorf("failed to load JWKS: %s", s.loadErr)
+	}
+
+	return nil, ErrInvalidToken
+}
+
+// reload loads the key set again. It must be called with the mutex held,
+// which is released while the set is fetched.
+func (s *jwks) reload(now time.Time) {
+	loading := make(chan struct{})
+	s.loading = loading
+	s.loadedAt = now
+	s.mutex.Unlock()
+
+	keys, err := s.load()
+
+	s.mutex.Lock()
+	s.loading = nil
+	close(loading)
+
+	s.loadErr = err
+	if err == nil {
+		s.keys = keys
+	}
+}
+
+// find looks up a key in the loaded set.
+func (s *jwks) find(keyID string) (crypto.PublicKey, bool) {
+	if keyID == "" && len(s.keys) == 1 {
+		for _, key := range s.keys {
+			return key, true
+		}
+	}
+
+	key, ok := s.keys[keyID]
+	return key, ok
+}
+
+// load reads the key set from the configured file or URL. Without either the
+// URL is discovered from the issuer's OpenID configuration.
+func (s *jwks) load() (map[string]crypto.PublicKey, error) {
+	var data []byte
+	var err error
+
+	switch {
+	case s.config.JWKSFile != "":
+		data, err = ioutil.ReadFile(s.config.JWKSFile)
+	case s.config.JWKSURL != "":
+		data, err = fetchJWKS(s.config.JWKSURL)
+	default:
+		data, err = discoverJWKS(s.config.Issuer)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return parseJWKS(data)
+}
+
+// discoverJWKS fetches the key set advertised by an issuer's OpenID
+// configuration.
+func discoverJWKS(issuer string) ([]byte, error) {
+	data, err := fetchJWKS(strings.TrimRight(issuer, "/") + "/.well-known/openid-configuration")
+	if err != nil {
+		return nil, err
+	}
+
+	var configuration struct {
+		JWKSURI string `json:"jwks_uri"`
+	}
+
+	if err := json.Unmarshal(data, &configuration); err != nil {
+		return nil, err
+	}
+
+	if configuration.JWKSURI == "" {
+		return nil, errors.New("issuer does not advertise a jwks_uri")
+	}
+
+	return fetchJWKS(configuration.JWKSURI)
+}
+
+// jwksClient is used to fetch key sets and OpenID configurations.
+var jwksClient = &http.Client{Timeout: 10 * time.Second}
+
+// fetchJWKS will GET a URL and return the body of a successful response.
+func fetchJWKS(url string) ([]byte, error) {
+	resp, err := jwksClient.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d from %s", resp.StatusCode, url)
+	}
+
+	return ioutil.ReadAll(resp.Body)
+}
+
+// parseJWKS parses the RSA and EC keys of a JSON Web Key Set. Other keys,
+// e.g. symmetric ones, are skipped.
+func parseJWKS(data []byte) (map[string]crypto.PublicKey, error) {
+	var set struct {
+		Keys []struct {
+			KeyType string `json:"kty"`
+			KeyID   string `json:"kid"`
+			Use     string `json:"use"`
+			N       string `json:"n"`
+			E       string `json:"e"`
+			Curve   string `json:"crv"`
+			X       string `json:"x"`
+			Y       string `json:"y"`
+		} `json:"keys"`
+	}
+
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, err
+	}
+
+	curves := map[string]elliptic.Curve{
+		"P-256": elliptic.P256(),
+		"P-384": elliptic.P384(),
+		"P-521": elliptic.P521(),
+	}
+
+	keys := make(map[string]crypto.PublicKey)
+	for _, key := range set.Keys {
+		if key.Use != "" && key.Use != "sig" {
+			continue
+		}
+
+		switch key.KeyType {
+		case "RSA":
+			n, err := base64.RawURLEncoding.DecodeString(key.N)
+			if err != nil {
+				return nil, fmt.Errorf("key %q: %s", key.KeyID, err)
+			}
+
+			e, err := base64.RawURLEncoding.DecodeString(key.E)
+			if err != nil {
+				return nil, fmt.Errorf("key %q: %s", key.KeyID, err)
+			}
+
+			keys[key.KeyID] = &rsa.PublicKey{
+				N: new(big.Int).SetBytes(n),
+				E: int(new(big.Int).SetBytes(e).Int64()),
+			}
+		case "EC":
+			curve, ok := curves[key.Curve]
+			if !ok {
+				continue
+			}
+
+			x, err := base64.RawURLEncoding.DecodeString(key.X)
+			if err != nil {
+				return nil, fmt.Errorf("key %q: %s", key.KeyID, err)
+			}
+
+			y, err := base64.RawURLEncoding.DecodeString(key.Y)
+			if err != nil {
+				return nil, fmt.Errorf("key %q: %s", key.KeyID, err)
+			}
+
+			keys[key.KeyID] = &ecdsa.PublicKey{
+				Curve: curve,
+				X:     new(big.Int).SetBytes(x),
+				Y:     new(big.Int).SetBytes(y),
+			}
+		}
+	}
+
+	return keys, nil
+}
@@ -0,0 +1,321 @@
+package broker
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/mongodb/mongodb-atlas-service-broker/pkg/atlas"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+const (
+	testIssuer   = "https://issuer.example.com"
+	testAudience = "atlas-broker"
+)
+
+// signToken creates a JWT signed with either an RSA or EC key.
+func signToken(t *testing.T, algorithm string, keyID string, key crypto.Signer, claims map[string]interface{}) string {
+	header, _ := json.Marshal(map[string]string{"alg": algorithm, "kid": keyID, "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+
+	hasher := crypto.SHA256.New()
+	hasher.Write([]byte(signed))
+	digest := hasher.Sum(nil)
+
+	var signature []byte
+	switch key := key.(type) {
+	case *rsa.PrivateKey:
+		signature, _ = rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest)
+	case *ecdsa.PrivateKey:
+		r, s, _ := ecdsa.Sign(rand.Reader, key, digest)
+		signature = make([]byte, 64)
+		rBytes, sBytes := r.Bytes(), s.Bytes()
+		copy(signature[32-len(rBytes):32], rBytes)
+		copy(signature[64-len(sBytes):], sBytes)
+	default:
+		t.Fatalf("Unsupported key %T", key)
+	}
+
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+// testJWKS returns a JSON Web Key Set containing the public keys.
+func testJWKS(keys map[string]crypto.Signer) []byte {
+	encode := func(i *big.Int) string { return base64.RawURLEncoding.EncodeToString(i.Bytes()) }
+
+	set := []map[string]string{}
+	for keyID, key := range keys {
+		switch key := key.(type) {
+		case *rsa.PrivateKey:
+			set = append(set, map[string]string{"kty": "RSA", "kid": keyID, "use": "sig", "n": encode(key.N), "e": encode(big.NewInt(int64(key.E)))})
+		case *ecdsa.PrivateKey:
+			set = append(set, map[string]string{"kty": "EC", "kid": keyID, "crv": "P-256", "x": encode(key.X), "y": encode(key.Y)})
+		}
+	}
+
+	data, _ := json.Marshal(map[string]interface{}{"keys": set})
+	return data
+}
+
+func testClaims(overrides map[string]interface{}) map[string]interface{} {
+	claims := map[string]interface{}{
+		"iss":       testIssuer,
+		"aud":       []string{testAudience},
+		"exp":       time.Now().Add(time.Hour).Unix(),
+		"scope":     "openid atlas.manage",
+		"client_id": "team-a-platform",
+	}
+
+	for key, value := range overrides {
+		claims[key] = value
+	}
+
+	return claims
+}
+
+func TestVerifyJWT(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	otherKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+
+	file, _ := ioutil.TempFile("", "jwks")
+	defer os.Remove(file.Name())
+	file.Write(testJWKS(map[string]crypto.Signer{"rsa": rsaKey, "ec": ecKey}))
+	file.Close()
+
+	verifier := newJWTVerifier(JWTConfig{
+		Issuer:   testIssuer,
+		Audience: testAudience,
+		Scopes:   []string{"atlas.manage"},
+		JWKSFile: file.Name(),
+	})
+
+	now := time.Now()
+	verify := func(token string) error {
+		_, err := verifier.verify(token, now)
+		return err
+	}
+
+	assert.NoError(t, verify(signToken(t, "RS256", "rsa", rsaKey, testClaims(nil))))
+	assert.NoError(t, verify(signToken(t, "ES256", "ec", ecKey, testClaims(nil))))
+
+	invalidTokens := map[string]string{
+		"wrong issuer":    signToken(t, "RS256", "rsa", rsaKey, testClaims(map[string]interface{}{"iss": "https://other.example.com"})),
+		"wrong audience":  signToken(t, "RS256", "rsa", rsaKey, testClaims(map[string]interface{}{"aud": "other"})),
+		"expired":         signToken(t, "RS256", "rsa", rsaKey, testClaims(map[string]interface{}{"exp": now.Add(-time.Hour).Unix()})),
+		"not yet valid":   signToken(t, "RS256", "rsa", rsaKey, testClaims(map[string]interface{}{"nbf": now.Add(time.Hour).Unix()})),
+		"unknown key":     signToken(t, "RS256", "other", otherKey, testClaims(nil)),
+		"wrong key":       signToken(t, "RS256", "rsa", otherKey, testClaims(nil)),
+		"wrong algorithm": signToken(t, "ES256", "rsa", ecKey, testClaims(nil)),
+		"malformed":       "not-a-token",
+	}
+
+	for name, token := range invalidTokens {
+		assert.Equalf(t, ErrInvalidToken, verify(token), "Expected %s token to be rejected", name)
+	}
+
+	// Tokens using the none algorithm are never accepted.
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none","kid":"rsa"}`))
+	payload, _ := json.Marshal(testClaims(nil))
+	assert.Equal(t, ErrInvalidToken, verify(header+"."+base64.RawURLEncoding.EncodeToString(payload)+"."))
+
+	err := verify(signToken(t, "RS256", "rsa", rsaKey, testClaims(map[string]interface{}{"scope": "openid"})))
+	assert.Equal(t, ErrInsufficientScope, err)
+
+	err = verify(signToken(t, "RS256", "rsa", rsaKey, testClaims(map[string]interface{}{"scope": nil, "scp": []string{"atlas.manage"}})))
+	assert.NoError(t, err)
+}
+
+func TestJWKSDiscovery(t *testing.T) {
+	oldKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	newKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	keys := map[string]crypto.Signer{"old": oldKey}
+
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/.well-known/openid-configuration":
+			json.NewEncoder(w).Encode(map[string]string{"jwks_uri": server.URL + "/keys"})
+		case "/keys":
+			w.Write(testJWKS(keys))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	verifier := newJWTVerifier(JWTConfig{
+		Issuer:   server.URL,
+		Audience: testAudience,
+	})
+
+	claims := testClaims(map[string]interface{}{"iss": server.URL})
+	now := time.Now()
+
+	_, err := verifier.verify(signToken(t, "RS256", "old", oldKey, claims), now)
+	assert.NoError(t, err)
+
+	// The issuer rotates its keys. The key set is only reloaded after the
+	// refresh interval.
+	keys["new"] = newKey
+	_, err = verifier.verify(signToken(t, "RS256", "new", newKey, claims), now)
+	assert.Equal(t, ErrInvalidToken, err)
+
+	_, err = verifier.verify(signToken(t, "RS256", "new", newKey, claims), now.Add(jwksRefreshInterval))
+	assert.NoError(t, err)
+}
+
+func TestJWKSLoadFailure(t *testing.T) {
+	key, _ := rsa.GenerateKey(rand.Reader, 2048)
+
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		w.Write(testJWKS(map[string]crypto.Signer{"key": key}))
+	}))
+	defer server.Close()
+
+	verifier := newJWTVerifier(JWTConfig{
+		Issuer:   testIssuer,
+		Audience: testAudience,
+		JWKSURL:  server.URL,
+	})
+
+	token := signToken(t, "RS256", "key", key, testClaims(nil))
+	now := time.Now()
+
+	_, err := verifier.verify(token, now)
+	assert.Error(t, err)
+
+	// Failed loads are only retried after the refresh interval.
+	_, err = verifier.verify(token, now)
+	assert.Error(t, err)
+	assert.Equal(t, 1, requests)
+
+	_, err = verifier.verify(token, now.Add(jwksRefreshInterval))
+	assert.NoError(t, err)
+	assert.Equal(t, 2, requests)
+}
+
+func TestParseInvalidJWTConfig(t *testing.T) {
+	invalidCredentials := []string{
+		// Missing audience.
+		`{"atlas": {"a": {"groupId": "g", "publicKey": "p", "privateKey": "k"}}, "jwt": {"issuer": "i", "mappings": [{"claim": "sub", "value": "*", "atlas": "a"}]}}`,
+		// Both a JWKS file and URL.
+		`{"atlas": {"a": {"groupId": "g", "publicKey": "p", "privateKey": "k"}}, "jwt": {"issuer": "i", "audience": "a", "jwksFile": "f", "jwksUrl": "u", "mappings": [{"claim": "sub", "value": "*", "atlas": "a"}]}}`,
+		// Mapping to an unknown Atlas API key.
+		`{"atlas": {"a": {"groupId": "g", "publicKey": "p", "privateKey": "k"}}, "jwt": {"issuer": "i", "audience": "a", "mappings": [{"claim": "sub", "value": "*", "atlas": "b"}]}}`,
+		// Neither mappings nor routes.
+		`{"atlas": {"a": {"groupId": "g", "publicKey": "p", "privateKey": "k"}}, "jwt": {"issuer": "i", "audience": "a"}}`,
+		// Mapping to both an Atlas API key and a platform.
+		`{"atlas": {"a": {"groupId": "g", "publicKey": "p", "privateKey": "k"}}, "jwt": {"issuer": "i", "audience": "a", "mappings": [{"claim": "sub", "value": "*", "atlas": "a", "platform": "kubernetes"}]}, "routes": [{"atlas": "a"}]}`,
+		// Mapping to a platform without routes.
+		`{"atlas": {"a": {"groupId": "g", "publicKey": "p", "privateKey": "k"}}, "jwt": {"issuer": "i", "audience": "a", "mappings": [{"claim": "sub", "value": "*", "platform": "kubernetes"}]}}`,
+	}
+
+	for _, credentials := range invalidCredentials {
+		_, err := ParseCredentials([]byte(credentials))
+		assert.Errorf(t, err, "Expected credentials %s to be rejected", credentials)
+	}
+}
+
+func TestCredentialsMiddlewareJWT(t *testing.T) {
+	key, _ := rsa.GenerateKey(rand.Reader, 2048)
+
+	file, _ := ioutil.TempFile("", "jwks")
+	defer os.Remove(file.Name())
+	file.Write(testJWKS(map[string]crypto.Signer{"key": key}))
+	file.Close()
+
+	credentials, err := ParseCredentials([]byte(`{
+		"atlas": {
+			"team-a": {"groupId": "group-a", "publicKey": "public-key", "privateKey": "private-key"}
+		},
+		"platforms": [
+			{"username": "platform", "password": "password", "atlas": "team-a"}
+		],
+		"jwt": {
+			"issuer": "` + testIssuer + `",
+			"audience": "` + testAudience + `",
+			"scopes": ["atlas.manage"],
+			"jwksFile": "` + file.Name() + `",
+			"mappings": [{"claim": "client_id", "value": "team-a-*", "atlas": "team-a"}]
+		},
+		"atlasBasicAuth": true
+	}`))
+
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	middleware := CredentialsMiddleware(zap.NewNop().Sugar(), "http://baseURL", credentials)
+
+	var client *atlas.HTTPClient
+	testHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		client, _ = r.Context().Value(ContextKeyAtlasClient).(*atlas.HTTPClient)
+	})
+
+	serve := func(setup func(r *http.Request)) int {
+		client = nil
+
+		req, _ := http.NewRequest("GET", "http://test", nil)
+		setup(req)
+
+		w := httptest.NewRecorder()
+		middleware(testHandler).ServeHTTP(w, req)
+		return w.Result().StatusCode
+	}
+
+	bearer := func(token string) func(r *http.Request) {
+		return func(r *http.Request) { r.Header.Set("Authorization", "Bearer "+token) }
+	}
+
+	status := serve(bearer(signToken(t, "RS256", "key", key, testClaims(nil))))
+	assert.Equal(t, http.StatusOK, status)
+	if assert.NotNil(t, client) {
+		assert.Equal(t, "group-a", client.GroupID)
+	}
+
+	// Tokens with claims which aren't mapped are forbidden.
+	status = serve(bearer(signToken(t, "RS256", "key", key, testClaims(map[string]interface{}{"client_id": "team-b"}))))
+	assert.Equal(t, http.StatusForbidden, status)
+
+	status = serve(bearer(signToken(t, "RS256", "key", key, testClaims(map[string]interface{}{"scope": "openid"}))))
+	assert.Equal(t, http.StatusForbidden, status)
+
+	status = serve(bearer("invalid"))
+	assert.Equal(t, http.StatusUnauthorized, status)
+
+	// Platform credentials and Atlas API keys passed using basic auth remain
+	// available.
+	status = serve(func(r *http.Request) { r.SetBasicAuth("platform", "password") })
+	assert.Equal(t, http.StatusOK, status)
+	if assert.NotNil(t, client) {
+		assert.Equal(t, "group-a", client.GroupID)
+	}
+
+	status = serve(func(r *http.Request) { r.SetBasicAuth("public-key@group-b", "private-key") })
+	assert.Equal(t, http.StatusOK, status)
+	if assert.NotNil(t, client) {
+		assert.Equal(t, "group-b", client.GroupID)
+	}
+}
@@ -0,0 +1,122 @@
+package broker
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/mongodb/mongodb-atlas-service-broker/pkg/atlas"
+	"github.com/pivotal-cf/brokerapi/domain/apiresponses"
+)
+
+// The labels describing which instance a cluster belongs to and which
+// platform it was created from. They make it possible to attribute costs
+// and find the owner of a cluster from the Atlas UI.
+const (
+	LabelInstanceID       = "aosb-instance-id"
+	LabelServiceID        = "aosb-service-id"
+	LabelPlanID           = "aosb-plan-id"
+	LabelPlatform         = "aosb-platform"
+	LabelOrganizationGUID = "aosb-cf-organization-guid"
+	LabelSpaceGUID        = "aosb-cf-space-guid"
+	LabelNamespace        = "aosb-k8s-namespace"
+	LabelClusterID        = "aosb-k8s-clusterid"
+)
+
+// platformContext is the context passed by the platform with every request
+// as defined by the OSB spec profiles for Cloud Foundry and Kubernetes.
+type platformContext struct {
+	Platform         string `json:"platform"`
+	OrganizationGUID string `json:"organization_guid"`
+	OrganizationName string `json:"organization_name"`
+	SpaceGUID        string `json:"space_guid"`
+	SpaceName        string `json:"space_name"`
+	Namespace        string `json:"namespace"`
+	ClusterID        string `json:"clusterid"`
+}
+
+// platformContextFromRaw will parse the raw context of a request. Platforms
+// which don't send a context result in an empty one.
+func platformContextFromRaw(rawContext json.RawMessage) (platformContext, error) {
+	var context platformContext
+	if len(rawContext) == 0 {
+		return context, nil
+	}
+
+	err := json.Unmarshal(rawContext, &context)
+	if err != nil {
+		return context, apiresponses.NewFailureResponse(err, http.StatusBadRequest, "invalid-context")
+	}
+
+	return context, nil
+}
+
+// contextLabels returns the labels identifying the instance and platform
+// context of a cluster. Empty values are left out so that updates which
+// don't include e.g. the plan keep the existing label.
+func contextLabels(instanceID string, serviceID string, planID string, context platformContext) []atlas.Label {
+	values := []atlas.Label{
+		{Key: LabelInstanceID, Value: instanceID},
+		{Key: LabelServiceID, Value: serviceID},
+		{Key: LabelPlanID, Value: planID},
+		{Key: LabelPlatform, Value: context.Platform},
+		{Key: LabelOrganizationGUID, Value: context.OrganizationGUID},
+		{Key: LabelSpaceGUID, Value: context.SpaceGUID},
+		{Key: LabelNamespace, Value: context.Namespace},
+		{Key: LabelClusterID, Value: context.ClusterID},
+	}
+
+	labels := []atlas.Label{}
+	for _, label := range values {
+		if label.Value != "" {
+			labels = append(labels, label)
+		}
+	}
+
+	return labels
+}
+
+// setLabels will set the updated labels on a list of labels. Existing labels
+// with the same key are replaced in place, others are appended.
+func setLabels(labels []atlas.Label, updated []atlas.Label) []atlas.Label {
+	result := append([]atlas.Label{}, labels...)
+
+	for _, label := range updated {
+		replaced := false
+		for i := range result {
+			if result[i].Key == label.Key {
+				result[i].Value = label.Value
+				replaced = true
+			}
+		}
+
+		if !replaced {
+			result = append(result, label)
+		}
+	}
+
+	return result
+}
+
+// labelValue returns the value of the label with the given key or an empty
+// string if there is none.
+func labelValue(labels []atlas.Label, key string) string {
+	for _, label := range labels {
+		if label.Key == key {
+			return label.Value
+		}
+	}
+
+	return ""
+}
+
+// instanceIDFromCluster will recover the full instance ID of a cluster. The
+// cluster name is a truncated version of it so it is only used for clusters
+// created before the instance ID was stored as a label.
+func instanceIDFromCluster(cluster atlas.Cluster) string {
+	instanceID := labelValue(cluster.Labels, LabelInstanceID)
+	if instanceID == "" {
+		return cluster.Name
+	}
+
+	return instanceID
+}
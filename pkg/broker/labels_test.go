@@ -0,0 +1,79 @@
+package broker
+
+import (
+	"testing"
+
+	"github.com/mongodb/mongodb-atlas-service-broker/pkg/atlas"
+	"github.com/pivotal-cf/brokerapi"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProvisionContextLabels(t *testing.T) {
+	broker, client, ctx := setupTest()
+
+	instanceID := "4b0a8a2c-9d6e-4f4e-8a2b-0d5d7b7c1e3f"
+	_, err := broker.Provision(ctx, instanceID, brokerapi.ProvisionDetails{
+		PlanID:     testPlanID,
+		ServiceID:  testServiceID,
+		RawContext: []byte(`{"platform": "cloudfoundry", "organization_guid": "org", "space_guid": "space"}`),
+	}, true)
+
+	assert.NoError(t, err)
+
+	cluster := client.Clusters[NormalizeClusterName(instanceID)]
+	expected := []atlas.Label{
+		atlas.Label{Key: LabelInstanceID, Value: instanceID},
+		atlas.Label{Key: LabelServiceID, Value: testServiceID},
+		atlas.Label{Key: LabelPlanID, Value: testPlanID},
+		atlas.Label{Key: LabelPlatform, Value: "cloudfoundry"},
+		atlas.Label{Key: LabelOrganizationGUID, Value: "org"},
+		atlas.Label{Key: LabelSpaceGUID, Value: "space"},
+	}
+	assert.Equal(t, expected, cluster.Labels)
+	assert.Equal(t, instanceID, instanceIDFromCluster(*cluster), "Expected full instance ID to be recovered from the labels")
+}
+
+func TestUpdateContextLabels(t *testing.T) {
+	broker, client, ctx := setupTest()
+
+	instanceID := "instance"
+	broker.Provision(ctx, instanceID, brokerapi.ProvisionDetails{
+		PlanID:     testPlanID,
+		ServiceID:  testServiceID,
+		RawContext: []byte(`{"platform": "kubernetes", "namespace": "default", "clusterid": "cluster"}`),
+	}, true)
+
+	_, err := broker.Update(ctx, instanceID, brokerapi.UpdateDetails{
+		PlanID:    "aosb-cluster-plan-aws-m20",
+		ServiceID: testServiceID,
+	}, true)
+
+	assert.NoError(t, err)
+
+	labels := client.Clusters[instanceID].Labels
+	assert.Equal(t, "aosb-cluster-plan-aws-m20", labelValue(labels, LabelPlanID), "Expected plan label to be updated")
+	assert.Equal(t, "default", labelValue(labels, LabelNamespace), "Expected existing labels to be kept")
+	assert.Equal(t, "cluster", labelValue(labels, LabelClusterID), "Expected existing labels to be kept")
+}
+
+func TestUpdatePauseWithoutLabels(t *testing.T) {
+	broker, client, ctx := setupTest()
+
+	instanceID := "instance"
+	broker.Provision(ctx, instanceID, brokerapi.ProvisionDetails{
+		PlanID:    testPlanID,
+		ServiceID: testServiceID,
+	}, true)
+
+	_, err := broker.Update(ctx, instanceID, brokerapi.UpdateDetails{
+		ServiceID:     testServiceID,
+		RawParameters: []byte(`{"cluster": {"paused": true}}`),
+	}, true)
+
+	assert.NoError(t, err)
+	assert.Nil(t, client.Clusters[instanceID].Labels, "Expected unchanged labels to not be sent")
+}
+
+func TestInstanceIDFromClusterWithoutLabels(t *testing.T) {
+	assert.Equal(t, "cluster", instanceIDFromCluster(atlas.Cluster{Name: "cluster"}))
+}
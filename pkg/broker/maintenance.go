@@ -0,0 +1,88 @@
+package broker
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/pivotal-cf/brokerapi"
+	"github.com/pivotal-cf/brokerapi/domain/apiresponses"
+)
+
+// maintenanceInfo returns the maintenance_info which will be attached to all
+// plans in the catalog. The OSB spec requires the version to be a semantic
+// version hence the MongoDB major version "4.2" is advertised as "4.2.0".
+// Returns nil if the broker has no MongoDB version configured.
+func (b Broker) maintenanceInfo() *brokerapi.MaintenanceInfo {
+	if b.mongoDBVersion == "" {
+		return nil
+	}
+
+	return &brokerapi.MaintenanceInfo{
+		Version: b.mongoDBVersion + ".0",
+		Public: map[string]string{
+			"mongoDBMajorVersion": b.mongoDBVersion,
+		},
+	}
+}
+
+// applyMaintenanceInfo will attach the broker's maintenance_info to all plans
+// of a service.
+func (b Broker) applyMaintenanceInfo(svc brokerapi.Service) brokerapi.Service {
+	info := b.maintenanceInfo()
+	if info == nil {
+		return svc
+	}
+
+	plans := make([]brokerapi.ServicePlan, len(svc.Plans))
+	for i, plan := range svc.Plans {
+		plan.MaintenanceInfo = info
+		plans[i] = plan
+	}
+
+	svc.Plans = plans
+	return svc
+}
+
+// validateMaintenanceInfo will make sure the maintenance_info passed by the
+// platform matches the one in the catalog. Platforms which don't pass any
+// maintenance_info are always accepted.
+func (b Broker) validateMaintenanceInfo(info brokerapi.MaintenanceInfo) error {
+	if info.NilOrEmpty() {
+		return nil
+	}
+
+	expected := b.maintenanceInfo()
+	if expected == nil {
+		return apiresponses.ErrMaintenanceInfoNilConflict
+	}
+
+	if info.Version != expected.Version {
+		return apiresponses.ErrMaintenanceInfoConflict
+	}
+
+	return nil
+}
+
+// mongoDBVersionFromMaintenanceInfo will convert the semantic version in a
+// maintenance_info back into a MongoDB major version, e.g. "4.2.0" to "4.2".
+func mongoDBVersionFromMaintenanceInfo(info brokerapi.MaintenanceInfo) (string, error) {
+	parts := strings.Split(info.Version, ".")
+	if len(parts) != 3 {
+		err := fmt.Errorf("invalid maintenance_info version %q", info.Version)
+		return "", apiresponses.NewFailureResponse(err, http.StatusBadRequest, "invalid-maintenance-info")
+	}
+
+	return parts[0] + "." + parts[1], nil
+}
+
+// isUpgradeRequest checks if an update request only carries a change to the
+// maintenance_info, which is how platforms request version upgrades
+// (e.g. "cf update-service --upgrade").
+func isUpgradeRequest(details brokerapi.UpdateDetails) bool {
+	if details.MaintenanceInfo.NilOrEmpty() || len(details.RawParameters) > 0 {
+		return false
+	}
+
+	return details.PlanID == "" || details.PlanID == details.PreviousValues.PlanID
+}
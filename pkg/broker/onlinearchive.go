@@ -0,0 +1,290 @@
+package broker
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/mongodb/mongodb-atlas-service-broker/pkg/atlas"
+	"github.com/pivotal-cf/brokerapi"
+	"github.com/pivotal-cf/brokerapi/domain/apiresponses"
+)
+
+// LabelOnlineArchivePrefix is the prefix of the cluster labels tracking the
+// online archives created by the broker. The value of each label is the
+// namespace of an archive, the key is derived from it as keys must be unique.
+const LabelOnlineArchivePrefix = "aosb-online-archive-"
+
+// The date formats supported for date criteria.
+var onlineArchiveDateFormats = []string{"ISODATE", "EPOCH_SECONDS", "EPOCH_MILLIS", "EPOCH_NANOSECONDS"}
+
+// onlineArchivesFromParams will parse the online archives passed as
+// "onlineArchives" in the params. The result is nil if no archives were
+// passed and empty if an empty list was passed, which removes all archives.
+func onlineArchivesFromParams(rawParams []byte) ([]atlas.OnlineArchive, error) {
+	params := struct {
+		OnlineArchives []atlas.OnlineArchive `json:"onlineArchives"`
+	}{}
+
+	if len(rawParams) > 0 {
+		err := json.Unmarshal(rawParams, &params)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	seen := map[string]bool{}
+	for i, archive := range params.OnlineArchives {
+		err := validateOnlineArchive(archive)
+		if err != nil {
+			return nil, invalidOnlineArchivesError(fmt.Errorf("online archive %d: %s", i, err))
+		}
+
+		// Only a single archive is managed per collection.
+		namespace := archive.DBName + "." + archive.CollName
+		if seen[namespace] {
+			return nil, invalidOnlineArchivesError(fmt.Errorf("duplicate online archive for %s", namespace))
+		}
+		seen[namespace] = true
+
+		// Read-only attributes are managed by Atlas.
+		params.OnlineArchives[i].ID = ""
+		params.OnlineArchives[i].State = ""
+	}
+
+	return params.OnlineArchives, nil
+}
+
+// validateOnlineArchive makes sure an online archive has a collection and
+// valid criteria.
+func validateOnlineArchive(archive atlas.OnlineArchive) error {
+	if archive.DBName == "" || archive.CollName == "" {
+		return fmt.Errorf("dbName and collName are required")
+	}
+
+	criteria := archive.Criteria
+	if criteria == nil {
+		return fmt.Errorf("criteria are required")
+	}
+
+	switch criteria.Type {
+	case atlas.OnlineArchiveCriteriaDate:
+		if criteria.DateField == "" {
+			return fmt.Errorf("dateField is required for %s criteria", criteria.Type)
+		}
+
+		if criteria.ExpireAfterDays < 1 {
+			return fmt.Errorf("expireAfterDays must be at least 1")
+		}
+
+		if criteria.DateFormat != "" && !containsString(onlineArchiveDateFormats, criteria.DateFormat) {
+			return fmt.Errorf("invalid dateFormat %q, expected one of %v", criteria.DateFormat, onlineArchiveDateFormats)
+		}
+	case atlas.OnlineArchiveCriteriaCustom:
+		if criteria.Query == "" {
+			return fmt.Errorf("query is required for %s criteria", criteria.Type)
+		}
+
+		// The query is passed as a string but needs to be a JSON document.
+		var query map[string]interface{}
+		if err := json.Unmarshal([]byte(criteria.Query), &query); err != nil {
+			return fmt.Errorf("query is not a valid JSON document: %s", err)
+		}
+	default:
+		return fmt.Errorf("invalid criteria type %q", criteria.Type)
+	}
+
+	return nil
+}
+
+// onlineArchiveNamespaces returns the namespaces of a list of archives.
+func onlineArchiveNamespaces(archives []atlas.OnlineArchive) []string {
+	namespaces := []string{}
+	for _, archive := range archives {
+		namespaces = append(namespaces, archive.DBName+"."+archive.CollName)
+	}
+
+	return namespaces
+}
+
+// managedOnlineArchives returns the namespaces of the online archives created
+// by the broker as tracked in the cluster labels.
+func managedOnlineArchives(labels []atlas.Label) []string {
+	namespaces := []string{}
+	for _, label := range labels {
+		if strings.HasPrefix(label.Key, LabelOnlineArchivePrefix) {
+			namespaces = append(namespaces, label.Value)
+		}
+	}
+
+	return namespaces
+}
+
+// trackOnlineArchives will replace the labels tracking the online archives
+// created by the broker with labels for the given namespaces.
+func trackOnlineArchives(labels []atlas.Label, namespaces []string) []atlas.Label {
+	result := []atlas.Label{}
+	for _, label := range labels {
+		if !strings.HasPrefix(label.Key, LabelOnlineArchivePrefix) {
+			result = append(result, label)
+		}
+	}
+
+	for _, namespace := range namespaces {
+		hash := sha256.Sum256([]byte(namespace))
+		result = append(result, atlas.Label{
+			Key:   LabelOnlineArchivePrefix + hex.EncodeToString(hash[:8]),
+			Value: namespace,
+		})
+	}
+
+	return result
+}
+
+// reconcileOnlineArchives will make the online archives of a cluster match the
+// desired archives. Archives are matched by collection. Missing archives are
+// created and changed archives are updated. Archives for collections which
+// aren't part of desired are only deleted if the broker created them, as
+// listed in managed, since deleting an archive deletes the archived data.
+func reconcileOnlineArchives(client atlas.Client, clusterName string, desired []atlas.OnlineArchive, managed []string) error {
+	existing, err := client.ListOnlineArchives(clusterName)
+	if err != nil {
+		return err
+	}
+
+	existingByNamespace := map[string]atlas.OnlineArchive{}
+	for _, archive := range existing {
+		if archive.State != atlas.OnlineArchiveStateDeleted {
+			existingByNamespace[archive.DBName+"."+archive.CollName] = archive
+		}
+	}
+
+	for _, archive := range desired {
+		namespace := archive.DBName + "." + archive.CollName
+		existingArchive, ok := existingByNamespace[namespace]
+		delete(existingByNamespace, namespace)
+
+		if !ok {
+			_, err = client.CreateOnlineArchive(clusterName, archive)
+		} else if onlineArchiveChanged(existingArchive, archive) {
+			archive.ID = existingArchive.ID
+			_, err = client.UpdateOnlineArchive(clusterName, archive)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	// Any remaining archives created by the broker are no longer wanted.
+	for namespace, archive := range existingByNamespace {
+		if !containsString(managed, namespace) {
+			continue
+		}
+
+		err = client.DeleteOnlineArchive(clusterName, archive.ID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// onlineArchivesState will create any of the desired online archives which
+// don't exist yet and determine the state of the operation from the state of
+// the archives. The operation is finished once all archives are active, or
+// paused if they were requested to be paused.
+func onlineArchivesState(client atlas.Client, clusterName string, desired []atlas.OnlineArchive) (brokerapi.LastOperationState, string, error) {
+	existing, err := client.ListOnlineArchives(clusterName)
+	if err != nil {
+		return brokerapi.Failed, "", err
+	}
+
+	total := len(desired)
+	ready := 0
+
+	for _, archive := range desired {
+		existingArchive := findOnlineArchive(existing, archive.DBName, archive.CollName)
+		if existingArchive == nil {
+			_, err = client.CreateOnlineArchive(clusterName, archive)
+			if err != nil {
+				return brokerapi.Failed, "", err
+			}
+
+			continue
+		}
+
+		switch existingArchive.State {
+		case atlas.OnlineArchiveStateActive:
+			if !archive.Paused {
+				ready++
+			}
+		case atlas.OnlineArchiveStatePaused:
+			if archive.Paused {
+				ready++
+			}
+		case atlas.OnlineArchiveStateOrphaned:
+			description := fmt.Sprintf("Online archive for %s.%s is orphaned", archive.DBName, archive.CollName)
+			return brokerapi.Failed, description, nil
+		}
+	}
+
+	description := fmt.Sprintf("Configuring online archives (%d/%d ready)", ready, total)
+	if ready == total {
+		return brokerapi.Succeeded, description, nil
+	}
+
+	return brokerapi.InProgress, description, nil
+}
+
+// findOnlineArchive will find the archive of a collection which hasn't been
+// deleted.
+func findOnlineArchive(archives []atlas.OnlineArchive, dbName string, collName string) *atlas.OnlineArchive {
+	for i := range archives {
+		archive := &archives[i]
+		if archive.DBName == dbName && archive.CollName == collName && archive.State != atlas.OnlineArchiveStateDeleted {
+			return archive
+		}
+	}
+
+	return nil
+}
+
+// onlineArchiveChanged checks if the updatable attributes of an existing
+// archive differ from its desired definition. Only the criteria which were
+// set are compared as Atlas fills in defaults such as the dateFormat.
+func onlineArchiveChanged(existing atlas.OnlineArchive, desired atlas.OnlineArchive) bool {
+	if existing.Paused != desired.Paused {
+		return true
+	}
+
+	if existing.Criteria == nil || desired.Criteria == nil {
+		return existing.Criteria != desired.Criteria
+	}
+
+	current, wanted := *existing.Criteria, *desired.Criteria
+	return current.Type != wanted.Type ||
+		(wanted.DateField != "" && current.DateField != wanted.DateField) ||
+		(wanted.DateFormat != "" && current.DateFormat != wanted.DateFormat) ||
+		(wanted.ExpireAfterDays != 0 && current.ExpireAfterDays != wanted.ExpireAfterDays) ||
+		(wanted.Query != "" && current.Query != wanted.Query)
+}
+
+// containsString checks if a string is part of a list.
+func containsString(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+func invalidOnlineArchivesError(err error) error {
+	return apiresponses.NewFailureResponse(err, http.StatusBadRequest, "invalid-online-archives")
+}
@@ -0,0 +1,198 @@
+package broker
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/mongodb/mongodb-atlas-service-broker/pkg/atlas"
+	"github.com/pivotal-cf/brokerapi"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProvisionOnlineArchives(t *testing.T) {
+	broker, client, ctx := setupTest()
+
+	params := `{
+		"onlineArchives": [{
+			"dbName": "db",
+			"collName": "events",
+			"criteria": {
+				"type": "DATE",
+				"dateField": "created",
+				"expireAfterDays": 90
+			}
+		}]
+	}`
+
+	instanceID := "instance"
+	res, err := broker.Provision(ctx, instanceID, brokerapi.ProvisionDetails{
+		PlanID:        testPlanID,
+		ServiceID:     testServiceID,
+		RawParameters: []byte(params),
+	}, true)
+
+	assert.NoError(t, err)
+	assert.Empty(t, client.OnlineArchives[instanceID])
+
+	client.SetClusterState(instanceID, atlas.ClusterStateIdle)
+	resp, err := broker.LastOperation(ctx, instanceID, brokerapi.PollDetails{
+		OperationData: res.OperationData,
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, brokerapi.InProgress, resp.State)
+	assert.Len(t, client.OnlineArchives[instanceID], 1)
+
+	client.SetOnlineArchiveState(instanceID, atlas.OnlineArchiveStateActive)
+	resp, err = broker.LastOperation(ctx, instanceID, brokerapi.PollDetails{
+		OperationData: res.OperationData,
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, brokerapi.Succeeded, resp.State)
+}
+
+func TestUpdateOnlineArchives(t *testing.T) {
+	broker, client, ctx := setupTest()
+
+	instanceID := "instance"
+	broker.Provision(ctx, instanceID, brokerapi.ProvisionDetails{
+		PlanID:    testPlanID,
+		ServiceID: testServiceID,
+	}, true)
+
+	// Archives which weren't created by the broker are never deleted.
+	criteria := &atlas.OnlineArchiveCriteria{Type: atlas.OnlineArchiveCriteriaDate, DateField: "created", ExpireAfterDays: 30}
+	client.CreateOnlineArchive(instanceID, atlas.OnlineArchive{DBName: "db", CollName: "unmanaged", Criteria: criteria})
+
+	update := func(params string) {
+		_, err := broker.Update(ctx, instanceID, brokerapi.UpdateDetails{
+			ServiceID:     testServiceID,
+			RawParameters: []byte(params),
+		}, true)
+
+		assert.NoError(t, err)
+	}
+
+	update(`{
+		"onlineArchives": [
+			{"dbName": "db", "collName": "events", "criteria": {"type": "DATE", "dateField": "created", "expireAfterDays": 30}},
+			{"dbName": "db", "collName": "removed", "criteria": {"type": "DATE", "dateField": "created", "expireAfterDays": 30}}
+		]
+	}`)
+
+	assert.Len(t, client.OnlineArchives[instanceID], 3)
+	assert.Equal(t, []string{"db.events", "db.removed"}, managedOnlineArchives(client.Clusters[instanceID].Labels))
+
+	update(`{
+		"onlineArchives": [
+			{"dbName": "db", "collName": "events", "criteria": {"type": "DATE", "dateField": "created", "expireAfterDays": 60}}
+		]
+	}`)
+
+	archives := client.OnlineArchives[instanceID]
+	if assert.Len(t, archives, 2, "Expected archives created by the broker which aren't listed to be deleted") {
+		assert.Equal(t, "unmanaged", archives[0].CollName)
+		assert.Equal(t, "events", archives[1].CollName)
+		assert.Equal(t, 60, archives[1].Criteria.ExpireAfterDays)
+	}
+
+	// An empty list removes all archives created by the broker.
+	update(`{"onlineArchives": []}`)
+
+	archives = client.OnlineArchives[instanceID]
+	if assert.Len(t, archives, 1) {
+		assert.Equal(t, "unmanaged", archives[0].CollName)
+	}
+
+	assert.Empty(t, managedOnlineArchives(client.Clusters[instanceID].Labels))
+}
+
+// rejectingOnlineArchiveClient is a mock client for which Atlas rejects new
+// online archives.
+type rejectingOnlineArchiveClient struct {
+	MockAtlasClient
+}
+
+func (c rejectingOnlineArchiveClient) CreateOnlineArchive(clusterName string, archive atlas.OnlineArchive) (*atlas.OnlineArchive, error) {
+	return nil, &atlas.Error{StatusCode: http.StatusBadRequest, Code: "INVALID_ATTRIBUTE", Description: "Invalid criteria"}
+}
+
+func TestUpdateOnlineArchivesRejected(t *testing.T) {
+	broker, client, ctx := setupTest()
+
+	instanceID := "instance"
+	broker.Provision(ctx, instanceID, brokerapi.ProvisionDetails{
+		PlanID:    testPlanID,
+		ServiceID: testServiceID,
+	}, true)
+
+	rejectingCtx := context.WithValue(context.Background(), ContextKeyAtlasClient, rejectingOnlineArchiveClient{client})
+	_, err := broker.Update(rejectingCtx, instanceID, brokerapi.UpdateDetails{
+		ServiceID:     testServiceID,
+		RawParameters: []byte(`{"onlineArchives": [{"dbName": "db", "collName": "events", "criteria": {"type": "DATE", "dateField": "created", "expireAfterDays": 30}}]}`),
+	}, true)
+
+	// Archives which couldn't be created aren't tracked.
+	assert.Error(t, err)
+	assert.Empty(t, managedOnlineArchives(client.Clusters[instanceID].Labels))
+}
+
+func TestOnlineArchiveChanged(t *testing.T) {
+	existing := atlas.OnlineArchive{
+		Criteria: &atlas.OnlineArchiveCriteria{
+			Type:            atlas.OnlineArchiveCriteriaDate,
+			DateField:       "created",
+			DateFormat:      "ISODATE",
+			ExpireAfterDays: 30,
+		},
+	}
+
+	// The dateFormat default filled in by Atlas is not a change.
+	desired := atlas.OnlineArchive{
+		Criteria: &atlas.OnlineArchiveCriteria{
+			Type:            atlas.OnlineArchiveCriteriaDate,
+			DateField:       "created",
+			ExpireAfterDays: 30,
+		},
+	}
+
+	assert.False(t, onlineArchiveChanged(existing, desired))
+
+	desired.Criteria.ExpireAfterDays = 60
+	assert.True(t, onlineArchiveChanged(existing, desired))
+
+	desired.Criteria.ExpireAfterDays = 30
+	desired.Paused = true
+	assert.True(t, onlineArchiveChanged(existing, desired))
+}
+
+func TestInvalidOnlineArchives(t *testing.T) {
+	broker, client, ctx := setupTest()
+
+	invalidParams := []string{
+		// Missing collection.
+		`{"onlineArchives": [{"dbName": "db", "criteria": {"type": "DATE", "dateField": "created", "expireAfterDays": 1}}]}`,
+		// Missing date field.
+		`{"onlineArchives": [{"dbName": "db", "collName": "c", "criteria": {"type": "DATE", "expireAfterDays": 1}}]}`,
+		// Invalid date format.
+		`{"onlineArchives": [{"dbName": "db", "collName": "c", "criteria": {"type": "DATE", "dateField": "d", "dateFormat": "UNIX", "expireAfterDays": 1}}]}`,
+		// Invalid custom query.
+		`{"onlineArchives": [{"dbName": "db", "collName": "c", "criteria": {"type": "CUSTOM", "query": "{invalid"}}]}`,
+		// Unknown criteria type.
+		`{"onlineArchives": [{"dbName": "db", "collName": "c", "criteria": {"type": "SIZE"}}]}`,
+	}
+
+	for _, params := range invalidParams {
+		_, err := broker.Provision(ctx, "instance", brokerapi.ProvisionDetails{
+			PlanID:        testPlanID,
+			ServiceID:     testServiceID,
+			RawParameters: []byte(params),
+		}, true)
+
+		assert.Errorf(t, err, "Expected params %s to be rejected", params)
+	}
+
+	assert.Len(t, client.Clusters, 0, "Expected no clusters to be created")
+}
@@ -0,0 +1,175 @@
+package broker
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/mongodb/mongodb-atlas-service-broker/pkg/atlas"
+	"github.com/pivotal-cf/brokerapi"
+	"github.com/pivotal-cf/brokerapi/domain/apiresponses"
+)
+
+// maximumOperationDataLength is the maximum length of the operation data
+// allowed by the OSB spec.
+const maximumOperationDataLength = 10000
+
+// The kinds of instances an async operation can be performed on. Clusters
+// are the default so operation data without a kind refers to a cluster.
+const (
+	instanceKindCluster    = ""
+	instanceKindServerless = "serverless"
+)
+
+// operation describes an async operation and any work which remains to be
+// done once Atlas has finished with the instance. It is serialized into the
+// operation data returned to the platform and passed back on every poll,
+// which lets the broker finish the operation without keeping any state.
+type operation struct {
+	// Type is one of the operation constants, e.g. OperationProvision.
+	Type string `json:"-"`
+
+	// Kind is the kind of instance the operation is performed on. Platforms
+	// may leave out the service ID when polling so it's carried along.
+	Kind string `json:"kind,omitempty"`
+
+	// SearchIndexes are the search indexes which should exist on the
+	// cluster once the operation has finished.
+	SearchIndexes []atlas.SearchIndex `json:"searchIndexes,omitempty"`
+
+	// OnlineArchives are the online archives which should exist on the
+	// cluster once the operation has finished. An empty, non-nil list
+	// removes all archives created by the broker.
+	OnlineArchives []atlas.OnlineArchive `json:"onlineArchives,omitempty"`
+
+	// ProcessArgs are the advanced configuration options which should be
+	// set on the cluster once the operation has finished.
+	ProcessArgs *atlas.ProcessArgs `json:"processArgs,omitempty"`
+}
+
+// operationFromParams will construct an operation of the given type from the
+// parts of the raw parameters which can only be applied once Atlas has
+// finished with the instance.
+func operationFromParams(operationType string, rawParams []byte) (operation, error) {
+	op := operation{Type: operationType}
+
+	searchIndexes, err := searchIndexesFromParams(rawParams)
+	if err != nil {
+		return op, err
+	}
+	op.SearchIndexes = searchIndexes
+
+	onlineArchives, err := onlineArchivesFromParams(rawParams)
+	if err != nil {
+		return op, err
+	}
+	op.OnlineArchives = onlineArchives
+
+	processArgs, err := processArgsFromParams(rawParams)
+	if err != nil {
+		return op, err
+	}
+	op.ProcessArgs = processArgs
+
+	return op, nil
+}
+
+// hasPendingWork checks if there is work which needs to be done once Atlas has
+// finished with the instance.
+func (o operation) hasPendingWork() bool {
+	return len(o.SearchIndexes) > 0 || len(o.OnlineArchives) > 0 || o.ProcessArgs != nil
+}
+
+// encode will serialize an operation into operation data. Operations on
+// clusters without pending work are encoded as just their type, otherwise the
+// kind and pending work are appended as base64-encoded JSON, e.g.
+// "provision:eyJzZWFyY2hJbmRleGVzIjpbXX0=".
+func (o operation) encode() (string, error) {
+	if !o.hasPendingWork() && o.Kind == instanceKindCluster {
+		return o.Type, nil
+	}
+
+	data, err := json.Marshal(o)
+	if err != nil {
+		return "", err
+	}
+
+	encoded := o.Type + ":" + base64.URLEncoding.EncodeToString(data)
+	if len(encoded) > maximumOperationDataLength {
+		err := errors.New("the requested configuration is too large to be applied in a single operation")
+		return "", apiresponses.NewFailureResponse(err, http.StatusBadRequest, "configuration-too-large")
+	}
+
+	return encoded, nil
+}
+
+// apply will immediately apply the pending work of an operation to an
+// existing cluster. Used during updates where the cluster already exists.
+// Process arguments are left to finish as the cluster is still being updated
+// at this point. The labels of the cluster before the update tell which
+// search indexes and online archives the broker created.
+func (o operation) apply(client atlas.Client, cluster *atlas.Cluster) error {
+	if len(o.SearchIndexes) > 0 {
+		err := reconcileSearchIndexes(client, cluster.Name, o.SearchIndexes, managedSearchIndexes(cluster.Labels))
+		if err != nil {
+			return err
+		}
+	}
+
+	if o.OnlineArchives != nil {
+		err := reconcileOnlineArchives(client, cluster.Name, o.OnlineArchives, managedOnlineArchives(cluster.Labels))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// finish will complete the pending work of an operation once Atlas has
+// finished with the cluster and report on its progress. The returned state
+// replaces the state of the operation.
+func (o operation) finish(client atlas.Client, clusterName string) (brokerapi.LastOperationState, string, error) {
+	if o.ProcessArgs != nil {
+		state, description, err := processArgsState(client, clusterName, *o.ProcessArgs)
+		if err != nil || state != brokerapi.Succeeded {
+			return state, description, err
+		}
+	}
+
+	if len(o.SearchIndexes) > 0 {
+		state, description, err := searchIndexesState(client, clusterName, o.SearchIndexes)
+		if err != nil || state != brokerapi.Succeeded {
+			return state, description, err
+		}
+	}
+
+	if len(o.OnlineArchives) > 0 {
+		state, description, err := onlineArchivesState(client, clusterName, o.OnlineArchives)
+		if err != nil || state != brokerapi.Succeeded {
+			return state, description, err
+		}
+	}
+
+	return brokerapi.Succeeded, "", nil
+}
+
+// decodeOperation will deserialize operation data created by encode.
+func decodeOperation(data string) (operation, error) {
+	parts := strings.SplitN(data, ":", 2)
+
+	op := operation{Type: parts[0]}
+	if len(parts) == 1 {
+		return op, nil
+	}
+
+	decoded, err := base64.URLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return op, err
+	}
+
+	err = json.Unmarshal(decoded, &op)
+	return op, err
+}
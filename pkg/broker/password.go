@@ -0,0 +1,180 @@
+package broker
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// PasswordGenerator generates the passwords of binding users. Operators can
+// plug in their own generator, e.g. to use a corporate secret service, with
+// WithPasswordGenerator.
+type PasswordGenerator interface {
+	GeneratePassword() (string, error)
+}
+
+// The character classes passwords can be made of.
+const (
+	PasswordCharactersLowercase = "lowercase"
+	PasswordCharactersUppercase = "uppercase"
+	PasswordCharactersDigits    = "digits"
+	PasswordCharactersSymbols   = "symbols"
+)
+
+var passwordCharacters = map[string]string{
+	PasswordCharactersLowercase: "abcdefghijklmnopqrstuvwxyz",
+	PasswordCharactersUppercase: "ABCDEFGHIJKLMNOPQRSTUVWXYZ",
+	PasswordCharactersDigits:    "0123456789",
+	PasswordCharactersSymbols:   "!#$%&()*+,-./:;<=>?@[]^_{|}~",
+}
+
+// minimumPasswordLength is the shortest password Atlas accepts.
+const minimumPasswordLength = 8
+
+// PasswordPolicy describes the passwords generated for binding users. Every
+// password contains at least one character of each class. Passwords are made
+// of single characters only, so unlike encoded random bytes they never
+// contain padding.
+type PasswordPolicy struct {
+	Length           int
+	CharacterClasses []string
+
+	// ExcludedCharacters are never used, e.g. characters which are hard to
+	// tell apart or need quoting in shells.
+	ExcludedCharacters string
+}
+
+// DefaultPasswordPolicy generates alphanumeric passwords which can be used in
+// URIs and shells without escaping.
+var DefaultPasswordPolicy = PasswordPolicy{
+	Length: 32,
+	CharacterClasses: []string{
+		PasswordCharactersLowercase,
+		PasswordCharactersUppercase,
+		PasswordCharactersDigits,
+	},
+}
+
+// WithPasswordGenerator configures how passwords of binding users are
+// generated, either using a PasswordPolicy or a custom generator.
+func WithPasswordGenerator(generator PasswordGenerator) Option {
+	return func(b *Broker) {
+		b.passwordGenerator = generator
+	}
+}
+
+// ParsePasswordCharacterClasses will parse a comma-separated list of
+// character classes, e.g. "lowercase,digits".
+func ParsePasswordCharacterClasses(value string) []string {
+	var classes []string
+	for _, class := range strings.Split(value, ",") {
+		if class = strings.TrimSpace(class); class != "" {
+			classes = append(classes, class)
+		}
+	}
+
+	return classes
+}
+
+// Validate makes sure passwords can be generated using the policy.
+func (p PasswordPolicy) Validate() error {
+	if len(p.CharacterClasses) == 0 {
+		return errors.New("password policy requires at least one character class")
+	}
+
+	for _, class := range p.CharacterClasses {
+		characters, ok := passwordCharacters[class]
+		if !ok {
+			return fmt.Errorf("unknown password character class %q", class)
+		}
+
+		if p.allowed(characters) == "" {
+			return fmt.Errorf("password character class %q has no characters left after exclusions", class)
+		}
+	}
+
+	if p.Length < minimumPasswordLength || p.Length < len(p.CharacterClasses) {
+		return fmt.Errorf("password length must be at least %d and cover every character class", minimumPasswordLength)
+	}
+
+	return nil
+}
+
+// GeneratePassword will generate a cryptographically secure password
+// following the policy.
+func (p PasswordPolicy) GeneratePassword() (string, error) {
+	if err := p.Validate(); err != nil {
+		return "", err
+	}
+
+	password := make([]byte, 0, p.Length)
+	alphabet := ""
+
+	// Pick one character of every class so each is represented.
+	for _, class := range p.CharacterClasses {
+		characters := p.allowed(passwordCharacters[class])
+		alphabet += characters
+
+		c, err := randomCharacter(characters)
+		if err != nil {
+			return "", err
+		}
+
+		password = append(password, c)
+	}
+
+	for len(password) < p.Length {
+		c, err := randomCharacter(alphabet)
+		if err != nil {
+			return "", err
+		}
+
+		password = append(password, c)
+	}
+
+	// Shuffle so the required characters aren't always at the start.
+	for i := len(password) - 1; i > 0; i-- {
+		j, err := rand.Int(rand.Reader, big.NewInt(int64(i+1)))
+		if err != nil {
+			return "", err
+		}
+
+		password[i], password[j.Int64()] = password[j.Int64()], password[i]
+	}
+
+	return string(password), nil
+}
+
+// allowed removes the excluded characters from a set of characters.
+func (p PasswordPolicy) allowed(characters string) string {
+	return strings.Map(func(r rune) rune {
+		if strings.ContainsRune(p.ExcludedCharacters, r) {
+			return -1
+		}
+
+		return r
+	}, characters)
+}
+
+// randomCharacter picks a uniformly random character from a set.
+func randomCharacter(characters string) (byte, error) {
+	i, err := rand.Int(rand.Reader, big.NewInt(int64(len(characters))))
+	if err != nil {
+		return 0, err
+	}
+
+	return characters[i.Int64()], nil
+}
+
+// generatePassword will generate the password of a binding user using the
+// configured generator or DefaultPasswordPolicy.
+func (b Broker) generatePassword() (string, error) {
+	generator := b.passwordGenerator
+	if generator == nil {
+		generator = DefaultPasswordPolicy
+	}
+
+	return generator.GeneratePassword()
+}
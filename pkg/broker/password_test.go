@@ -0,0 +1,96 @@
+package broker
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/pivotal-cf/brokerapi"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+type staticPasswordGenerator string
+
+func (g staticPasswordGenerator) GeneratePassword() (string, error) {
+	return string(g), nil
+}
+
+func TestPasswordPolicy(t *testing.T) {
+	policy := PasswordPolicy{
+		Length:             16,
+		CharacterClasses:   []string{PasswordCharactersDigits, PasswordCharactersSymbols},
+		ExcludedCharacters: "0123456789%",
+	}
+
+	_, err := policy.GeneratePassword()
+	assert.Error(t, err, "Expected policy without digits left to be rejected")
+
+	policy.ExcludedCharacters = "01%"
+	for i := 0; i < 20; i++ {
+		password, err := policy.GeneratePassword()
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		assert.Len(t, password, 16)
+		assert.True(t, strings.ContainsAny(password, "23456789"), "Expected password %q to contain a digit", password)
+		assert.True(t, strings.ContainsAny(password, passwordCharacters[PasswordCharactersSymbols]), "Expected password %q to contain a symbol", password)
+		assert.False(t, strings.ContainsAny(password, "01%"), "Expected password %q to not contain excluded characters", password)
+	}
+
+	password, err := DefaultPasswordPolicy.GeneratePassword()
+	if assert.NoError(t, err) {
+		assert.Len(t, password, 32)
+		assert.NotContains(t, password, "=")
+	}
+}
+
+func TestPasswordPolicyValidate(t *testing.T) {
+	invalidPolicies := []PasswordPolicy{
+		{Length: 32},
+		{Length: 4, CharacterClasses: []string{PasswordCharactersDigits}},
+		{Length: 32, CharacterClasses: []string{"emoji"}},
+	}
+
+	for _, policy := range invalidPolicies {
+		assert.Errorf(t, policy.Validate(), "Expected policy %v to be rejected", policy)
+	}
+
+	assert.NoError(t, DefaultPasswordPolicy.Validate())
+	assert.Equal(t, []string{"lowercase", "digits"}, ParsePasswordCharacterClasses(" lowercase, digits,"))
+}
+
+func TestBindPasswordGenerator(t *testing.T) {
+	client := newMockAtlasClient()
+	broker := NewBroker(zap.NewNop().Sugar(), WithPasswordGenerator(staticPasswordGenerator("corporate-secret")))
+	ctx := context.WithValue(context.Background(), ContextKeyAtlasClient, client)
+
+	instanceID := "instance"
+	broker.Provision(ctx, instanceID, brokerapi.ProvisionDetails{
+		PlanID:    testPlanID,
+		ServiceID: testServiceID,
+	}, true)
+
+	spec, err := broker.Bind(ctx, instanceID, "binding", brokerapi.BindDetails{
+		PlanID:    testPlanID,
+		ServiceID: testServiceID,
+	}, true)
+
+	if assert.NoError(t, err) {
+		assert.Equal(t, "corporate-secret", spec.Credentials.(ConnectionDetails).Password)
+		assert.Equal(t, "corporate-secret", client.Users["binding"].Password)
+	}
+
+	// Rotation uses the same generator.
+	client.Users["binding"].Password = "old"
+	spec, err = broker.RotateBinding(ctx, instanceID, "binding", brokerapi.BindDetails{
+		PlanID:    testPlanID,
+		ServiceID: testServiceID,
+	})
+
+	if assert.NoError(t, err) {
+		assert.Equal(t, "corporate-secret", spec.Credentials.(ConnectionDetails).Password)
+		assert.Equal(t, "corporate-secret", client.Users["binding"].Password)
+	}
+}
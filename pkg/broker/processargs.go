@@ -0,0 +1,114 @@
+package broker
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/mongodb/mongodb-atlas-service-broker/pkg/atlas"
+	"github.com/pivotal-cf/brokerapi"
+	"github.com/pivotal-cf/brokerapi/domain/apiresponses"
+)
+
+// The read concerns which can be set as a cluster's default.
+var processArgsReadConcerns = []string{"local", "available", "majority"}
+
+// processArgsFromParams will parse the advanced configuration options passed
+// as "processArgs" in the params. Returns nil if none were passed.
+func processArgsFromParams(rawParams []byte) (*atlas.ProcessArgs, error) {
+	params := struct {
+		ProcessArgs *atlas.ProcessArgs `json:"processArgs"`
+	}{}
+
+	if len(rawParams) > 0 {
+		err := json.Unmarshal(rawParams, &params)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if params.ProcessArgs == nil {
+		return nil, nil
+	}
+
+	err := validateProcessArgs(*params.ProcessArgs)
+	if err != nil {
+		return nil, apiresponses.NewFailureResponse(err, http.StatusBadRequest, "invalid-process-args")
+	}
+
+	return params.ProcessArgs, nil
+}
+
+// validateProcessArgs makes sure the options have values Atlas will accept.
+func validateProcessArgs(args atlas.ProcessArgs) error {
+	if args.DefaultReadConcern != "" && !containsString(processArgsReadConcerns, args.DefaultReadConcern) {
+		return fmt.Errorf("invalid defaultReadConcern %q, expected one of %v", args.DefaultReadConcern, processArgsReadConcerns)
+	}
+
+	// The write concern is either "majority" or a number of nodes.
+	if args.DefaultWriteConcern != "" && args.DefaultWriteConcern != "majority" {
+		nodes, err := strconv.Atoi(args.DefaultWriteConcern)
+		if err != nil || nodes < 0 {
+			return fmt.Errorf("invalid defaultWriteConcern %q, expected \"majority\" or a number of nodes", args.DefaultWriteConcern)
+		}
+	}
+
+	protocols := []string{atlas.TLSProtocol10, atlas.TLSProtocol11, atlas.TLSProtocol12}
+	if args.MinimumEnabledTLSProtocol != "" && !containsString(protocols, args.MinimumEnabledTLSProtocol) {
+		return fmt.Errorf("invalid minimumEnabledTlsProtocol %q, expected one of %v", args.MinimumEnabledTLSProtocol, protocols)
+	}
+
+	if args.OplogSizeMB < 0 || args.SampleSizeBIConnector < 0 || args.SampleRefreshIntervalBIConnector < 0 {
+		return fmt.Errorf("oplogSizeMB, sampleSizeBIConnector and sampleRefreshIntervalBIConnector cannot be negative")
+	}
+
+	return nil
+}
+
+// processArgsState will apply the process arguments to a cluster unless they
+// are already in place. Atlas updates the cluster to apply them so the
+// operation is in progress until the next poll finds them applied.
+func processArgsState(client atlas.Client, clusterName string, args atlas.ProcessArgs) (brokerapi.LastOperationState, string, error) {
+	existingArgs, err := client.GetProcessArgs(clusterName)
+	if err != nil {
+		return brokerapi.Failed, "", err
+	}
+
+	if !processArgsChanged(args, *existingArgs) {
+		return brokerapi.Succeeded, "", nil
+	}
+
+	_, err = client.UpdateProcessArgs(clusterName, args)
+	if err != nil {
+		return brokerapi.Failed, "", err
+	}
+
+	return brokerapi.InProgress, "Applying process arguments", nil
+}
+
+// processArgsChanged checks if any of the specified options differ from the
+// existing ones. Options which aren't specified are ignored.
+func processArgsChanged(args atlas.ProcessArgs, existing atlas.ProcessArgs) bool {
+	stringChanged := func(value string, existingValue string) bool {
+		return value != "" && value != existingValue
+	}
+
+	intChanged := func(value int, existingValue int) bool {
+		return value != 0 && value != existingValue
+	}
+
+	boolChanged := func(value *bool, existingValue *bool) bool {
+		return value != nil && (existingValue == nil || *value != *existingValue)
+	}
+
+	return stringChanged(args.DefaultReadConcern, existing.DefaultReadConcern) ||
+		stringChanged(args.DefaultWriteConcern, existing.DefaultWriteConcern) ||
+		stringChanged(args.MinimumEnabledTLSProtocol, existing.MinimumEnabledTLSProtocol) ||
+		boolChanged(args.FailIndexKeyTooLong, existing.FailIndexKeyTooLong) ||
+		boolChanged(args.JavascriptEnabled, existing.JavascriptEnabled) ||
+		boolChanged(args.NoTableScan, existing.NoTableScan) ||
+		intChanged(args.OplogSizeMB, existing.OplogSizeMB) ||
+		intChanged(args.SampleSizeBIConnector, existing.SampleSizeBIConnector) ||
+		intChanged(args.SampleRefreshIntervalBIConnector, existing.SampleRefreshIntervalBIConnector)
+}
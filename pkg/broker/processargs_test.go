@@ -0,0 +1,167 @@
+package broker
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/mongodb/mongodb-atlas-service-broker/pkg/atlas"
+	"github.com/pivotal-cf/brokerapi"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProvisionProcessArgs(t *testing.T) {
+	broker, client, ctx := setupTest()
+
+	params := `{
+		"processArgs": {
+			"defaultReadConcern": "majority",
+			"javascriptEnabled": false,
+			"minimumEnabledTlsProtocol": "TLS1_2"
+		}
+	}`
+
+	instanceID := "instance"
+	res, err := broker.Provision(ctx, instanceID, brokerapi.ProvisionDetails{
+		PlanID:        testPlanID,
+		ServiceID:     testServiceID,
+		RawParameters: []byte(params),
+	}, true)
+
+	assert.NoError(t, err)
+	assert.Nil(t, client.ProcessArgs[instanceID], "Expected process args to not be applied before the cluster exists")
+
+	client.SetClusterState(instanceID, atlas.ClusterStateIdle)
+	resp, err := broker.LastOperation(ctx, instanceID, brokerapi.PollDetails{
+		OperationData: res.OperationData,
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, brokerapi.InProgress, resp.State)
+
+	args := client.ProcessArgs[instanceID]
+	if assert.NotNil(t, args) {
+		assert.Equal(t, "majority", args.DefaultReadConcern)
+		assert.Equal(t, atlas.TLSProtocol12, args.MinimumEnabledTLSProtocol)
+		assert.False(t, *args.JavascriptEnabled)
+	}
+
+	// Atlas updates the cluster to apply the process args.
+	client.SetClusterState(instanceID, atlas.ClusterStateUpdating)
+	resp, err = broker.LastOperation(ctx, instanceID, brokerapi.PollDetails{
+		OperationData: res.OperationData,
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, brokerapi.InProgress, resp.State)
+
+	client.SetClusterState(instanceID, atlas.ClusterStateIdle)
+	resp, err = broker.LastOperation(ctx, instanceID, brokerapi.PollDetails{
+		OperationData: res.OperationData,
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, brokerapi.Succeeded, resp.State)
+}
+
+func TestUpdateProcessArgs(t *testing.T) {
+	broker, client, ctx := setupTest()
+
+	instanceID := "instance"
+	broker.Provision(ctx, instanceID, brokerapi.ProvisionDetails{
+		PlanID:    testPlanID,
+		ServiceID: testServiceID,
+	}, true)
+
+	client.ProcessArgs[instanceID] = &atlas.ProcessArgs{OplogSizeMB: 1024}
+
+	res, err := broker.Update(ctx, instanceID, brokerapi.UpdateDetails{
+		ServiceID:     testServiceID,
+		RawParameters: []byte(`{"processArgs": {"oplogSizeMB": 2048}}`),
+	}, true)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1024, client.ProcessArgs[instanceID].OplogSizeMB, "Expected process args to be applied once the update has finished")
+
+	client.SetClusterState(instanceID, atlas.ClusterStateIdle)
+	broker.LastOperation(ctx, instanceID, brokerapi.PollDetails{
+		OperationData: res.OperationData,
+	})
+
+	assert.Equal(t, 2048, client.ProcessArgs[instanceID].OplogSizeMB)
+
+	resp, err := broker.LastOperation(ctx, instanceID, brokerapi.PollDetails{
+		OperationData: res.OperationData,
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, brokerapi.Succeeded, resp.State)
+}
+
+func TestInvalidProcessArgs(t *testing.T) {
+	broker, client, ctx := setupTest()
+
+	invalidParams := []string{
+		`{"processArgs": {"defaultReadConcern": "linearizable"}}`,
+		`{"processArgs": {"defaultWriteConcern": "all"}}`,
+		`{"processArgs": {"minimumEnabledTlsProtocol": "SSL3"}}`,
+		`{"processArgs": {"oplogSizeMB": -1}}`,
+	}
+
+	for _, params := range invalidParams {
+		_, err := broker.Provision(ctx, "instance", brokerapi.ProvisionDetails{
+			PlanID:        testPlanID,
+			ServiceID:     testServiceID,
+			RawParameters: []byte(params),
+		}, true)
+
+		assert.Errorf(t, err, "Expected params %s to be rejected", params)
+	}
+
+	assert.Len(t, client.Clusters, 0, "Expected no clusters to be created")
+}
+
+// processArgsErrorClient is a mock client failing to update process args.
+type processArgsErrorClient struct {
+	MockAtlasClient
+	err error
+}
+
+func (c processArgsErrorClient) UpdateProcessArgs(clusterName string, args atlas.ProcessArgs) (*atlas.ProcessArgs, error) {
+	return nil, c.err
+}
+
+func TestProvisionProcessArgsErrors(t *testing.T) {
+	broker, client, ctx := setupTest()
+
+	instanceID := "instance"
+	res, err := broker.Provision(ctx, instanceID, brokerapi.ProvisionDetails{
+		PlanID:        testPlanID,
+		ServiceID:     testServiceID,
+		RawParameters: []byte(`{"processArgs": {"oplogSizeMB": 990}}`),
+	}, true)
+
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	client.SetClusterState(instanceID, atlas.ClusterStateIdle)
+
+	poll := func(updateErr error) (brokerapi.LastOperation, error) {
+		failingCtx := context.WithValue(context.Background(), ContextKeyAtlasClient, processArgsErrorClient{client, updateErr})
+		return broker.LastOperation(failingCtx, instanceID, brokerapi.PollDetails{
+			OperationData: res.OperationData,
+		})
+	}
+
+	// Failures to reach Atlas are returned so the platform polls again.
+	_, err = poll(errors.New("connection reset"))
+	assert.Error(t, err)
+
+	// Configuration rejected by Atlas fails the operation.
+	resp, err := poll(&atlas.Error{StatusCode: http.StatusBadRequest, Code: "INVALID_ATTRIBUTE", Description: "Invalid oplog size"})
+	assert.NoError(t, err)
+	assert.Equal(t, brokerapi.Failed, resp.State)
+	assert.Contains(t, resp.Description, "Invalid oplog size")
+}
@@ -0,0 +1,106 @@
+package broker
+
+import (
+	"context"
+
+	"github.com/mongodb/mongodb-atlas-service-broker/pkg/atlas"
+)
+
+// ContextKeyAtlasOrgClient is the key used to store the Atlas organization
+// client in the request context. Its presence enables project-per-instance
+// mode, where every instance lives in a dedicated Atlas project.
+var ContextKeyAtlasOrgClient = ContextKey("atlas-org-client")
+
+// maximumProjectNameLength is the maximum length of Atlas project names.
+const maximumProjectNameLength = 64
+
+// projectName returns the name of the dedicated project of an instance.
+func projectName(instanceID string) string {
+	if len(instanceID) > maximumProjectNameLength {
+		return instanceID[:maximumProjectNameLength]
+	}
+
+	return instanceID
+}
+
+// orgClientFromContext will retrieve the Atlas organization client stored
+// inside the provided context. Returns false if the broker is not running in
+// project-per-instance mode.
+func orgClientFromContext(ctx context.Context) (atlas.OrgClient, bool) {
+	client, ok := ctx.Value(ContextKeyAtlasOrgClient).(atlas.OrgClient)
+	return client, ok
+}
+
+// instanceClient will retrieve the Atlas client for the project an existing
+// instance lives in. In project-per-instance mode the instance's project is
+// looked up, otherwise the client from the context is used.
+func instanceClient(ctx context.Context, instanceID string) (atlas.Client, error) {
+	orgClient, ok := orgClientFromContext(ctx)
+	if !ok {
+		return atlasClientFromContext(ctx)
+	}
+
+	project, err := orgClient.GetProjectByName(projectName(instanceID))
+	if err != nil {
+		return nil, err
+	}
+
+	return orgClient.ProjectClient(project.ID), nil
+}
+
+// provisionClient will retrieve the Atlas client a new instance should be
+// created with. In project-per-instance mode the instance's project is
+// created, or reused if a previous attempt already created it. The returned
+// cleanup function removes a newly created project again and should be
+// called if provisioning fails.
+func provisionClient(ctx context.Context, instanceID string) (atlas.Client, func() error, error) {
+	noCleanup := func() error { return nil }
+
+	orgClient, ok := orgClientFromContext(ctx)
+	if !ok {
+		client, err := atlasClientFromContext(ctx)
+		return client, noCleanup, err
+	}
+
+	name := projectName(instanceID)
+	project, err := orgClient.CreateProject(name)
+	if err == atlas.ErrProjectAlreadyExists {
+		project, err = orgClient.GetProjectByName(name)
+		if err != nil {
+			return nil, noCleanup, err
+		}
+
+		return orgClient.ProjectClient(project.ID), noCleanup, nil
+	}
+
+	if err != nil {
+		return nil, noCleanup, err
+	}
+
+	cleanup := func() error {
+		return orgClient.DeleteProject(project.ID)
+	}
+
+	return orgClient.ProjectClient(project.ID), cleanup, nil
+}
+
+// deleteInstanceProject will delete the dedicated project of an instance once
+// the instance itself has been removed. Does nothing unless the broker is
+// running in project-per-instance mode.
+func deleteInstanceProject(ctx context.Context, instanceID string) error {
+	orgClient, ok := orgClientFromContext(ctx)
+	if !ok {
+		return nil
+	}
+
+	project, err := orgClient.GetProjectByName(projectName(instanceID))
+	if err == atlas.ErrProjectNotFound {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return orgClient.DeleteProject(project.ID)
+}
@@ -0,0 +1,195 @@
+package broker
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mongodb/mongodb-atlas-service-broker/pkg/atlas"
+	"github.com/pivotal-cf/brokerapi"
+	"github.com/pivotal-cf/brokerapi/domain/apiresponses"
+	"github.com/stretchr/testify/assert"
+)
+
+// MockOrgClient is a mock implementation of atlas.OrgClient. Every project
+// has its own MockAtlasClient.
+type MockOrgClient struct {
+	Projects map[string]*atlas.Project
+	Clients  map[string]MockAtlasClient
+}
+
+func (m MockOrgClient) CreateProject(name string) (*atlas.Project, error) {
+	if m.Projects[name] != nil {
+		return nil, atlas.ErrProjectAlreadyExists
+	}
+
+	project := &atlas.Project{
+		ID:   fmt.Sprintf("group-%d", len(m.Clients)),
+		Name: name,
+	}
+
+	m.Projects[name] = project
+	m.Clients[project.ID] = newMockAtlasClient()
+	return project, nil
+}
+
+func (m MockOrgClient) GetProjectByName(name string) (*atlas.Project, error) {
+	project := m.Projects[name]
+	if project == nil {
+		return nil, atlas.ErrProjectNotFound
+	}
+
+	return project, nil
+}
+
+func (m MockOrgClient) DeleteProject(id string) error {
+	for name, project := range m.Projects {
+		if project.ID == id {
+			delete(m.Projects, name)
+			return nil
+		}
+	}
+
+	return atlas.ErrProjectNotFound
+}
+
+func (m MockOrgClient) ProjectClient(groupID string) atlas.Client {
+	return m.Clients[groupID]
+}
+
+// projectClient returns the mock client of the project with the given name.
+func (m MockOrgClient) projectClient(name string) MockAtlasClient {
+	return m.Clients[m.Projects[name].ID]
+}
+
+func setupOrgTest() (*Broker, MockOrgClient, context.Context) {
+	broker, _, ctx := setupTest()
+
+	orgClient := MockOrgClient{
+		Projects: make(map[string]*atlas.Project),
+		Clients:  make(map[string]MockAtlasClient),
+	}
+
+	// Requests which aren't tied to an instance, such as the catalog, keep
+	// using the regular client.
+	ctx = context.WithValue(ctx, ContextKeyAtlasOrgClient, orgClient)
+
+	return broker, orgClient, ctx
+}
+
+func TestProvisionProjectPerInstance(t *testing.T) {
+	broker, orgClient, ctx := setupOrgTest()
+
+	instanceID := "instance"
+	_, err := broker.Provision(ctx, instanceID, brokerapi.ProvisionDetails{
+		PlanID:    testPlanID,
+		ServiceID: testServiceID,
+	}, true)
+
+	assert.NoError(t, err)
+
+	if assert.NotNil(t, orgClient.Projects[instanceID], "Expected a project to be created for the instance") {
+		client := orgClient.projectClient(instanceID)
+		assert.NotNil(t, client.Clusters[instanceID], "Expected the cluster to be created in the instance's project")
+	}
+}
+
+func TestProvisionProjectPerInstanceFailure(t *testing.T) {
+	broker, orgClient, ctx := setupOrgTest()
+
+	_, err := broker.Provision(ctx, "instance", brokerapi.ProvisionDetails{
+		PlanID:        testPlanID,
+		ServiceID:     testServiceID,
+		RawParameters: []byte(`{"cluster": {"paused": true}}`),
+	}, true)
+
+	assert.Error(t, err)
+	assert.Empty(t, orgClient.Projects, "Expected the project to be removed after provisioning failed")
+}
+
+func TestDeprovisionProjectPerInstance(t *testing.T) {
+	broker, orgClient, ctx := setupOrgTest()
+
+	instanceID := "instance"
+	broker.Provision(ctx, instanceID, brokerapi.ProvisionDetails{
+		PlanID:    testPlanID,
+		ServiceID: testServiceID,
+	}, true)
+
+	_, err := broker.Deprovision(ctx, instanceID, brokerapi.DeprovisionDetails{
+		PlanID:    testPlanID,
+		ServiceID: testServiceID,
+	}, true)
+
+	assert.NoError(t, err)
+	assert.NotNil(t, orgClient.Projects[instanceID], "Expected the project to be kept until the cluster is deleted")
+
+	orgClient.projectClient(instanceID).SetClusterState(instanceID, atlas.ClusterStateDeleted)
+	resp, err := broker.LastOperation(ctx, instanceID, brokerapi.PollDetails{
+		OperationData: OperationDeprovision,
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, brokerapi.Succeeded, resp.State)
+	assert.Nil(t, orgClient.Projects[instanceID], "Expected the project to be deleted")
+
+	// Polling again after the project is gone still succeeds.
+	resp, err = broker.LastOperation(ctx, instanceID, brokerapi.PollDetails{
+		OperationData: OperationDeprovision,
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, brokerapi.Succeeded, resp.State)
+}
+
+func TestBindMissingProject(t *testing.T) {
+	broker, _, ctx := setupOrgTest()
+
+	_, err := broker.Bind(ctx, "instance", "binding", brokerapi.BindDetails{
+		PlanID:    testPlanID,
+		ServiceID: testServiceID,
+	}, true)
+
+	assert.EqualError(t, err, apiresponses.ErrInstanceDoesNotExist.Error())
+}
+
+func TestOrgAuthMiddleware(t *testing.T) {
+	baseURL := "http://baseURL"
+	orgID := "org-id"
+	publicKey := "public-key"
+	privateKey := "private-key"
+
+	middleware := OrgAuthMiddleware(baseURL)
+
+	testHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		client, ok := r.Context().Value(ContextKeyAtlasOrgClient).(*atlas.HTTPClient)
+		if !assert.True(t, ok, "expected context to have org client") {
+			return
+		}
+
+		assert.Equal(t, orgID, client.OrgID)
+		assert.Empty(t, client.GroupID)
+		assert.Equal(t, publicKey, client.PublicKey)
+		assert.Equal(t, privateKey, client.PrivateKey)
+
+		_, ok = r.Context().Value(ContextKeyAtlasClient).(atlas.Client)
+		assert.True(t, ok, "expected context to have client")
+	})
+
+	req, err := http.NewRequest("GET", "http://test", nil)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	// Incorrect username format.
+	w := httptest.NewRecorder()
+	req.SetBasicAuth("incorrect-username", "password")
+	middleware(testHandler).ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Result().StatusCode)
+
+	// Valid credentials. testHandler will run and validate the context.
+	req.SetBasicAuth(publicKey+"@"+orgID, privateKey)
+	middleware(testHandler).ServeHTTP(httptest.NewRecorder(), req)
+}
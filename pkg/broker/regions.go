@@ -0,0 +1,137 @@
+package broker
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/mongodb/mongodb-atlas-service-broker/pkg/atlas"
+	"github.com/pivotal-cf/brokerapi/domain/apiresponses"
+)
+
+// The node types which can be specified in a region template.
+const (
+	NodeTypeElectable = "electable"
+	NodeTypeReadOnly  = "readOnly"
+	NodeTypeAnalytics = "analytics"
+)
+
+// maximumPriority is the priority given to the first electable region. Each
+// following electable region is given a priority one lower.
+const maximumPriority = 7
+
+// replicationSpecFromRegions will expand a list of region templates into a
+// replication spec. Each template is formatted as "<REGION>:<COUNT>" for
+// electable nodes or "<REGION>:<TYPE>:<COUNT>" for any node type, for example
+// "EU_WEST_1:analytics:1". Electable regions are prioritized in the order they
+// appear. If validateRegions is set all regions must be part of
+// availableRegions, callers skip validation when Atlas provides no list of
+// regions to validate against.
+func replicationSpecFromRegions(templates []string, availableRegions []atlas.Region, validateRegions bool) (*atlas.ReplicationSpec, error) {
+	regionsConfig := map[string]atlas.RegionsConfig{}
+	electableNodes := 0
+	priority := maximumPriority
+
+	for _, template := range templates {
+		region, nodeType, count, err := parseRegionTemplate(template)
+		if err != nil {
+			return nil, invalidRegionsError(err)
+		}
+
+		if validateRegions && !isRegionAvailable(region, availableRegions) {
+			return nil, invalidRegionsError(fmt.Errorf("region %q is not available for the selected plan", region))
+		}
+
+		config := regionsConfig[region]
+		switch nodeType {
+		case NodeTypeElectable:
+			// Regions are prioritized in the order their electable nodes are
+			// listed.
+			if config.ElectableNodes == 0 {
+				config.Priority = priority
+				priority--
+			}
+
+			config.ElectableNodes += count
+			electableNodes += count
+		case NodeTypeReadOnly:
+			config.ReadOnlyNodes += count
+		case NodeTypeAnalytics:
+			config.AnalyticsNodes += count
+		}
+
+		regionsConfig[region] = config
+	}
+
+	// Atlas requires replica sets to consist of 3, 5 or 7 electable nodes.
+	if electableNodes != 3 && electableNodes != 5 && electableNodes != 7 {
+		return nil, invalidRegionsError(fmt.Errorf("the number of electable nodes must be 3, 5 or 7 but was %d", electableNodes))
+	}
+
+	return &atlas.ReplicationSpec{
+		NumShards:     1,
+		ZoneName:      defaultZoneName,
+		RegionsConfig: regionsConfig,
+	}, nil
+}
+
+// parseRegionTemplate will split a single region template into its region,
+// node type and node count.
+func parseRegionTemplate(template string) (region string, nodeType string, count int, err error) {
+	parts := strings.Split(template, ":")
+
+	switch len(parts) {
+	case 2:
+		region, nodeType = parts[0], NodeTypeElectable
+	case 3:
+		region, nodeType = parts[0], parts[1]
+	default:
+		err = fmt.Errorf("invalid region %q, expected format <REGION>:[<TYPE>:]<COUNT>", template)
+		return
+	}
+
+	if region == "" {
+		err = fmt.Errorf("missing region name in %q", template)
+		return
+	}
+
+	if nodeType != NodeTypeElectable && nodeType != NodeTypeReadOnly && nodeType != NodeTypeAnalytics {
+		err = fmt.Errorf("invalid node type %q in %q", nodeType, template)
+		return
+	}
+
+	count, err = strconv.Atoi(parts[len(parts)-1])
+	if err != nil || count < 1 {
+		err = fmt.Errorf("invalid node count in %q", template)
+	}
+
+	return
+}
+
+// providerListsRegions checks if Atlas lists the available regions for any
+// instance size of a provider.
+func providerListsRegions(provider *atlas.Provider) bool {
+	for _, instanceSize := range provider.InstanceSizes {
+		if len(instanceSize.AvailableRegions) > 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isRegionAvailable checks if a region is part of a list of available regions.
+func isRegionAvailable(region string, availableRegions []atlas.Region) bool {
+	for _, availableRegion := range availableRegions {
+		if availableRegion.Name == region {
+			return true
+		}
+	}
+
+	return false
+}
+
+func invalidRegionsError(err error) error {
+	return apiresponses.NewFailureResponse(err, http.StatusBadRequest, "invalid-regions")
+}
@@ -0,0 +1,25 @@
+package broker
+
+import (
+	"testing"
+
+	"github.com/mongodb/mongodb-atlas-service-broker/pkg/atlas"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReplicationSpecFromRegionsValidation(t *testing.T) {
+	templates := []string{"AP_SOUTH_1:3"}
+
+	// Regions are rejected if they aren't listed, even if the list is empty.
+	_, err := replicationSpecFromRegions(templates, []atlas.Region{{Name: "US_EAST_1"}}, true)
+	assert.Error(t, err)
+
+	_, err = replicationSpecFromRegions(templates, nil, true)
+	assert.Error(t, err)
+
+	// Any region is accepted if there is no list to validate against.
+	spec, err := replicationSpecFromRegions(templates, nil, false)
+	if assert.NoError(t, err) {
+		assert.Equal(t, 3, spec.RegionsConfig["AP_SOUTH_1"].ElectableNodes)
+	}
+}
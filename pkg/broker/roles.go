@@ -0,0 +1,145 @@
+package broker
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/mongodb/mongodb-atlas-service-broker/pkg/atlas"
+	"github.com/pivotal-cf/brokerapi/domain/apiresponses"
+)
+
+// The role presets bindings can select using the "role" parameter. They are
+// scoped to the binding's database.
+const (
+	RolePresetReadOnly  = "readOnly"
+	RolePresetReadWrite = "readWrite"
+	RolePresetDBAdmin   = "dbAdmin"
+)
+
+// RolePolicyReadWriteAnyDatabase is the default role policy granting access
+// to every database in the project, matching the default of the Atlas UI.
+const RolePolicyReadWriteAnyDatabase = "readWriteAnyDatabase"
+
+// DefaultRolePolicy is the role bindings get unless they specify roles.
+const DefaultRolePolicy = RolePresetReadWrite
+
+// rolePresets maps presets to the built-in role they grant.
+var rolePresets = map[string]string{
+	RolePresetReadOnly:  "read",
+	RolePresetReadWrite: "readWrite",
+	RolePresetDBAdmin:   "dbAdmin",
+}
+
+// databaseRoles are the built-in roles which can be granted on any database.
+// Only read and readWrite can be limited to a collection.
+var databaseRoles = map[string]bool{
+	"read":      true,
+	"readWrite": true,
+	"dbAdmin":   false,
+	"dbOwner":   false,
+	"userAdmin": false,
+}
+
+// IsValidRolePolicy checks if a role policy is either a preset or
+// RolePolicyReadWriteAnyDatabase.
+func IsValidRolePolicy(policy string) bool {
+	_, ok := rolePresets[policy]
+	return ok || policy == RolePolicyReadWriteAnyDatabase
+}
+
+// WithDefaultRolePolicy configures the role bindings get unless they specify
+// roles, either a preset scoped to the binding's database or
+// RolePolicyReadWriteAnyDatabase.
+func WithDefaultRolePolicy(policy string) Option {
+	return func(b *Broker) {
+		b.defaultRolePolicy = policy
+	}
+}
+
+// rolesForPreset returns the roles granted by a preset on a database.
+func rolesForPreset(preset string, database string) ([]atlas.Role, error) {
+	if preset == RolePolicyReadWriteAnyDatabase {
+		return []atlas.Role{
+			atlas.Role{
+				Name:         "readWriteAnyDatabase",
+				DatabaseName: "admin",
+			},
+		}, nil
+	}
+
+	name, ok := rolePresets[preset]
+	if !ok {
+		return nil, fmt.Errorf("unknown role %q, accepted values: %s, %s, %s", preset, RolePresetReadOnly, RolePresetReadWrite, RolePresetDBAdmin)
+	}
+
+	return []atlas.Role{
+		atlas.Role{
+			Name:         name,
+			DatabaseName: database,
+		},
+	}, nil
+}
+
+// validateRoles makes sure roles are assigned the way Atlas accepts them.
+// Built-in database roles can target any database, other roles such as
+// readWriteAnyDatabase and custom roles only exist on the admin database.
+func validateRoles(roles []atlas.Role) error {
+	for _, role := range roles {
+		if role.Name == "" || role.DatabaseName == "" {
+			return fmt.Errorf("roles require a roleName and databaseName")
+		}
+
+		if err := validateDatabaseName(role.DatabaseName); err != nil {
+			return err
+		}
+
+		allowsCollection, isDatabaseRole := databaseRoles[role.Name]
+		if !isDatabaseRole && role.DatabaseName != "admin" {
+			return fmt.Errorf("role %q can only be granted on the admin database", role.Name)
+		}
+
+		if role.CollectionName != "" && !allowsCollection {
+			return fmt.Errorf("role %q can't be limited to a collection", role.Name)
+		}
+
+		if strings.HasPrefix(role.CollectionName, "system.") || strings.Contains(role.CollectionName, "$") {
+			return fmt.Errorf("invalid collection %q for role %q", role.CollectionName, role.Name)
+		}
+	}
+
+	return nil
+}
+
+// userRoles will determine the roles of a binding's user. Roles passed
+// explicitly are validated, otherwise the role preset or the broker's default
+// role policy is applied to the binding's database.
+func (b Broker) userRoles(roles []atlas.Role, preset string, database string) ([]atlas.Role, error) {
+	if len(roles) > 0 && preset != "" {
+		err := fmt.Errorf("only one of role and user.roles can be specified")
+		return nil, apiresponses.NewFailureResponse(err, http.StatusBadRequest, "invalid-roles")
+	}
+
+	if len(roles) > 0 {
+		if err := validateRoles(roles); err != nil {
+			return nil, apiresponses.NewFailureResponse(err, http.StatusBadRequest, "invalid-roles")
+		}
+
+		return roles, nil
+	}
+
+	if preset == "" {
+		preset = b.defaultRolePolicy
+	}
+
+	if preset == "" {
+		preset = DefaultRolePolicy
+	}
+
+	roles, err := rolesForPreset(preset, database)
+	if err != nil {
+		return nil, apiresponses.NewFailureResponse(err, http.StatusBadRequest, "invalid-roles")
+	}
+
+	return roles, nil
+}
@@ -0,0 +1,102 @@
+package broker
+
+import (
+	"context"
+	"testing"
+
+	"github.com/mongodb/mongodb-atlas-service-broker/pkg/atlas"
+	"github.com/pivotal-cf/brokerapi"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestValidateRoles(t *testing.T) {
+	validRoles := [][]atlas.Role{
+		{{Name: "read", DatabaseName: "orders", CollectionName: "items"}},
+		{{Name: "dbAdmin", DatabaseName: "orders"}},
+		{{Name: "dbOwner", DatabaseName: "orders"}},
+		{{Name: "userAdmin", DatabaseName: "orders"}},
+		{{Name: "readWriteAnyDatabase", DatabaseName: "admin"}},
+		{{Name: "customRole", DatabaseName: "admin"}},
+	}
+
+	for _, roles := range validRoles {
+		assert.NoErrorf(t, validateRoles(roles), "Expected roles %v to be accepted", roles)
+	}
+
+	invalidRoles := [][]atlas.Role{
+		{{Name: "read"}},
+		{{DatabaseName: "orders"}},
+		{{Name: "readWriteAnyDatabase", DatabaseName: "orders"}},
+		{{Name: "dbAdmin", DatabaseName: "orders", CollectionName: "items"}},
+		{{Name: "dbOwner", DatabaseName: "orders", CollectionName: "items"}},
+		{{Name: "read", DatabaseName: "my.database"}},
+		{{Name: "read", DatabaseName: "orders", CollectionName: "system.users"}},
+	}
+
+	for _, roles := range invalidRoles {
+		assert.Errorf(t, validateRoles(roles), "Expected roles %v to be rejected", roles)
+	}
+}
+
+func TestBindRolePreset(t *testing.T) {
+	broker, client, ctx := setupTest()
+
+	instanceID := "instance"
+	broker.Provision(ctx, instanceID, brokerapi.ProvisionDetails{
+		PlanID:    testPlanID,
+		ServiceID: testServiceID,
+	}, true)
+
+	_, err := broker.Bind(ctx, instanceID, "binding", brokerapi.BindDetails{
+		PlanID:        testPlanID,
+		ServiceID:     testServiceID,
+		RawParameters: []byte(`{"role": "readOnly", "database": "orders"}`),
+	}, true)
+
+	if assert.NoError(t, err) {
+		assert.Equal(t, []atlas.Role{
+			atlas.Role{Name: "read", DatabaseName: "orders"},
+		}, client.Users["binding"].Roles)
+	}
+
+	invalidParams := []string{
+		`{"role": "root"}`,
+		`{"role": "readOnly", "user": {"roles": [{"roleName": "read", "databaseName": "orders"}]}}`,
+		`{"user": {"roles": [{"roleName": "atlasAdmin", "databaseName": "orders"}]}}`,
+	}
+
+	for _, params := range invalidParams {
+		_, err := broker.Bind(ctx, instanceID, "invalid", brokerapi.BindDetails{
+			PlanID:        testPlanID,
+			ServiceID:     testServiceID,
+			RawParameters: []byte(params),
+		}, true)
+
+		assert.Errorf(t, err, "Expected params %s to be rejected", params)
+		assert.Nil(t, client.Users["invalid"], "Expected no user to be created")
+	}
+}
+
+func TestBindDefaultRolePolicy(t *testing.T) {
+	client := newMockAtlasClient()
+	broker := NewBroker(zap.NewNop().Sugar(), WithDefaultRolePolicy(RolePolicyReadWriteAnyDatabase))
+	ctx := context.WithValue(context.Background(), ContextKeyAtlasClient, client)
+
+	instanceID := "instance"
+	broker.Provision(ctx, instanceID, brokerapi.ProvisionDetails{
+		PlanID:    testPlanID,
+		ServiceID: testServiceID,
+	}, true)
+
+	_, err := broker.Bind(ctx, instanceID, "binding", brokerapi.BindDetails{
+		PlanID:    testPlanID,
+		ServiceID: testServiceID,
+	}, true)
+
+	if assert.NoError(t, err) {
+		assert.Equal(t, []atlas.Role{
+			atlas.Role{Name: "readWriteAnyDatabase", DatabaseName: "admin"},
+		}, client.Users["binding"].Roles)
+	}
+}
@@ -0,0 +1,242 @@
+package broker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/mongodb/mongodb-atlas-service-broker/pkg/atlas"
+	"github.com/pivotal-cf/brokerapi"
+	"github.com/pivotal-cf/brokerapi/domain/apiresponses"
+)
+
+// LabelBindingID is the label on database users containing the ID of the
+// binding currently using the user. Rotated credentials are handed to the
+// successor binding while the username stays the same.
+const LabelBindingID = "aosb-binding-id"
+
+// ContextKeyPredecessorBindingID is the key used to store the
+// predecessor_binding_id of a binding rotation request in the request context.
+var ContextKeyPredecessorBindingID = ContextKey("predecessor-binding-id")
+
+// BindingRotationMiddleware will parse the predecessor_binding_id of bind
+// requests as defined by the OSB spec for binding rotation and attach it to
+// the request context, as the brokerapi library doesn't support it.
+func BindingRotationMiddleware() mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodPut || mux.Vars(r)["binding_id"] == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			var details struct {
+				PredecessorBindingID string `json:"predecessor_binding_id"`
+			}
+
+			body := peekBody(r)
+			if len(body) > 0 && json.Unmarshal(body, &details) == nil && details.PredecessorBindingID != "" {
+				ctx := context.WithValue(r.Context(), ContextKeyPredecessorBindingID, details.PredecessorBindingID)
+				r = r.WithContext(ctx)
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// predecessorFromContext returns the binding a bind request rotates the
+// credentials of or an empty string if it's a regular bind request.
+func predecessorFromContext(ctx context.Context) string {
+	predecessor, _ := ctx.Value(ContextKeyPredecessorBindingID).(string)
+	return predecessor
+}
+
+// userForBinding will find the database user of a binding. Users are named
+// after the binding which created them, bindings which received rotated
+// credentials are found through LabelBindingID.
+func userForBinding(client atlas.Client, bindingID string) (*atlas.User, error) {
+	user, err := client.GetUser(bindingID)
+	if err != atlas.ErrUserNotFound {
+		return user, err
+	}
+
+	users, err := client.ListUsers()
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range users {
+		if labelValue(users[i].Labels, LabelBindingID) == bindingID {
+			return &users[i], nil
+		}
+	}
+
+	return nil, atlas.ErrUserNotFound
+}
+
+// isSuperseded checks if the credentials of a binding have been rotated to a
+// successor binding which now owns the user.
+func isSuperseded(user *atlas.User, bindingID string) bool {
+	owner := labelValue(user.Labels, LabelBindingID)
+	return owner != "" && owner != bindingID
+}
+
+// isBoundToInstance checks if a database user belongs to a binding of the
+// given instance, either through its label or by being scoped to it.
+func isBoundToInstance(user *atlas.User, instanceID string) bool {
+	if labelValue(user.Labels, LabelInstanceID) == instanceID {
+		return true
+	}
+
+	for _, scope := range user.Scopes {
+		if scope.Name == NormalizeClusterName(instanceID) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// rotateUser will set a new password for the database user of a binding and
+// return it. X.509 users get a new certificate instead, certificates issued
+// before stay valid until they expire. AWS IAM and LDAP users have no
+// credentials to rotate. If a successor binding is given it takes over the user.
+// Users of bindings to other instances are rejected.
+func (b Broker) rotateUser(client atlas.Client, instanceID string, bindingID string, successorID string) (*atlas.User, string, error) {
+	user, err := userForBinding(client, bindingID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if !isBoundToInstance(user, instanceID) {
+		err = fmt.Errorf("binding %s doesn't belong to instance %s", bindingID, instanceID)
+		return nil, "", apiresponses.NewFailureResponse(err, http.StatusUnprocessableEntity, "binding-instance-mismatch")
+	}
+
+	update := atlas.User{
+		Username:     user.Username,
+		DatabaseName: user.DatabaseName,
+	}
+
+	var secret string
+	if user.X509Type == atlas.X509TypeManaged {
+		secret, err = client.CreateUserCertificate(user.Username, certificateValidityMonths)
+		if err != nil {
+			return nil, "", err
+		}
+	} else if hasPassword(user) {
+		secret, err = b.generatePassword()
+		if err != nil {
+			b.logger.Errorw("Failed to generate password", "error", err, "binding_id", bindingID)
+			return nil, "", errors.New("Failed to generate binding password")
+		}
+
+		update.Password = secret
+	}
+
+	if successorID != "" {
+		update.Labels = setLabels(user.Labels, []atlas.Label{{Key: LabelBindingID, Value: successorID}})
+	}
+
+	// Nothing is left to update for external users rotated in place.
+	if update.Password == "" && update.Labels == nil {
+		return user, secret, nil
+	}
+
+	updated, err := client.UpdateUser(update)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return updated, secret, nil
+}
+
+// RotateBinding will set a new password, or issue a new certificate, for the
+// database user of an existing binding and return the new credentials. The
+// username stays the same so applications only need to pick up the new
+// password or certificate.
+func (b Broker) RotateBinding(ctx context.Context, instanceID string, bindingID string, details brokerapi.BindDetails) (spec brokerapi.Binding, err error) {
+	b.logger.Infow("Rotating binding credentials", "instance_id", instanceID, "binding_id", bindingID, "details", details)
+
+	client, err := instanceClient(ctx, instanceID)
+	if err != nil {
+		err = atlasToAPIError(err)
+		return
+	}
+
+	conn, err := instanceConnection(client, instanceID, details.ServiceID, details.PlanID)
+	if err != nil {
+		b.logger.Errorw("Failed to get existing cluster", "error", err, "instance_id", instanceID)
+		err = atlasToAPIError(err)
+		return
+	}
+
+	database, err := databaseFromParams(details.RawParameters)
+	if err != nil {
+		return
+	}
+
+	format, err := b.credentialsFormatFromParams(details.RawParameters)
+	if err != nil {
+		return
+	}
+
+	user, secret, err := b.rotateUser(client, instanceID, bindingID, "")
+	if err != nil {
+		b.logger.Errorw("Failed to rotate Atlas database user", "error", err, "instance_id", instanceID, "binding_id", bindingID)
+		err = atlasToAPIError(err)
+		return
+	}
+
+	b.logger.Infow("Successfully rotated Atlas database user", "instance_id", instanceID, "binding_id", bindingID, "username", user.Username)
+
+	spec = brokerapi.Binding{
+		Credentials: formatCredentials(format, userDetails(conn, user, secret, database)),
+	}
+	return
+}
+
+// AttachAdminRoutes will add the broker's admin endpoints to a router. They
+// are meant to be protected by the same auth middleware as the OSB API.
+//
+// POST /admin/v2/service_instances/{instance_id}/service_bindings/{binding_id}/rotate
+// rotates the credentials of a binding. The body is the same as for bind
+// requests and the response contains the new credentials.
+func AttachAdminRoutes(router *mux.Router, broker *Broker) {
+	router.HandleFunc("/admin/v2/service_instances/{instance_id}/service_bindings/{binding_id}/rotate", broker.handleRotateBinding).Methods(http.MethodPost)
+}
+
+// handleRotateBinding is the HTTP handler for binding rotation requests.
+func (b Broker) handleRotateBinding(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	w.Header().Set("Content-Type", "application/json")
+
+	var details brokerapi.BindDetails
+	if err := json.NewDecoder(r.Body).Decode(&details); err != nil {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		json.NewEncoder(w).Encode(apiresponses.ErrorResponse{Description: err.Error()})
+		return
+	}
+
+	spec, err := b.RotateBinding(r.Context(), vars["instance_id"], vars["binding_id"], details)
+	if err != nil {
+		status := http.StatusInternalServerError
+		var response interface{} = apiresponses.ErrorResponse{Description: err.Error()}
+
+		if failure, ok := err.(*apiresponses.FailureResponse); ok {
+			status = failure.ValidatedStatusCode(nil)
+			response = failure.ErrorResponse()
+		}
+
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(spec)
+}
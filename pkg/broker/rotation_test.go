@@ -0,0 +1,196 @@
+package broker
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/pivotal-cf/brokerapi"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBindRotation(t *testing.T) {
+	broker, client, ctx := setupTest()
+
+	instanceID := "instance"
+	broker.Provision(ctx, instanceID, brokerapi.ProvisionDetails{
+		PlanID:    testPlanID,
+		ServiceID: testServiceID,
+	}, true)
+
+	_, err := broker.Bind(ctx, instanceID, "binding", brokerapi.BindDetails{
+		PlanID:    testPlanID,
+		ServiceID: testServiceID,
+	}, true)
+
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	oldPassword := client.Users["binding"].Password
+
+	rotationCtx := context.WithValue(ctx, ContextKeyPredecessorBindingID, "binding")
+	spec, err := broker.Bind(rotationCtx, instanceID, "successor", brokerapi.BindDetails{
+		PlanID:    testPlanID,
+		ServiceID: testServiceID,
+	}, true)
+
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	// The user is kept and handed to the successor with a new password.
+	credentials := spec.Credentials.(ConnectionDetails)
+	assert.Equal(t, "binding", credentials.Username)
+	assert.NotEqual(t, oldPassword, credentials.Password)
+	assert.Equal(t, credentials.Password, client.Users["binding"].Password)
+	assert.Equal(t, "successor", labelValue(client.Users["binding"].Labels, LabelBindingID))
+	assert.Nil(t, client.Users["successor"], "Expected no new user to be created")
+
+	// Unbinding the predecessor keeps the user of the successor.
+	_, err = broker.Unbind(ctx, instanceID, "binding", brokerapi.UnbindDetails{
+		PlanID:    testPlanID,
+		ServiceID: testServiceID,
+	}, true)
+
+	assert.NoError(t, err)
+	assert.NotNil(t, client.Users["binding"])
+
+	_, err = broker.Unbind(ctx, instanceID, "successor", brokerapi.UnbindDetails{
+		PlanID:    testPlanID,
+		ServiceID: testServiceID,
+	}, true)
+
+	assert.NoError(t, err)
+	assert.Nil(t, client.Users["binding"])
+}
+
+func TestBindRotationMissingPredecessor(t *testing.T) {
+	broker, _, ctx := setupTest()
+
+	instanceID := "instance"
+	broker.Provision(ctx, instanceID, brokerapi.ProvisionDetails{
+		PlanID:    testPlanID,
+		ServiceID: testServiceID,
+	}, true)
+
+	rotationCtx := context.WithValue(ctx, ContextKeyPredecessorBindingID, "missing")
+	_, err := broker.Bind(rotationCtx, instanceID, "successor", brokerapi.BindDetails{
+		PlanID:    testPlanID,
+		ServiceID: testServiceID,
+	}, true)
+
+	assert.Error(t, err)
+}
+
+func TestBindRotationPredecessorOfOtherInstance(t *testing.T) {
+	broker, client, ctx := setupTest()
+
+	for _, instanceID := range []string{"instance", "other"} {
+		broker.Provision(ctx, instanceID, brokerapi.ProvisionDetails{
+			PlanID:    testPlanID,
+			ServiceID: testServiceID,
+		}, true)
+	}
+
+	_, err := broker.Bind(ctx, "other", "binding", brokerapi.BindDetails{
+		PlanID:    testPlanID,
+		ServiceID: testServiceID,
+	}, true)
+
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	oldPassword := client.Users["binding"].Password
+
+	rotationCtx := context.WithValue(ctx, ContextKeyPredecessorBindingID, "binding")
+	_, err = broker.Bind(rotationCtx, "instance", "successor", brokerapi.BindDetails{
+		PlanID:    testPlanID,
+		ServiceID: testServiceID,
+	}, true)
+
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "doesn't belong to instance")
+	}
+
+	assert.Equal(t, oldPassword, client.Users["binding"].Password)
+	assert.Equal(t, "binding", labelValue(client.Users["binding"].Labels, LabelBindingID))
+}
+
+func TestBindingRotationMiddleware(t *testing.T) {
+	var predecessor string
+	var body string
+
+	router := mux.NewRouter()
+	router.Use(BindingRotationMiddleware())
+	router.HandleFunc("/v2/service_instances/{instance_id}/service_bindings/{binding_id}", func(w http.ResponseWriter, r *http.Request) {
+		predecessor = predecessorFromContext(r.Context())
+
+		var details brokerapi.BindDetails
+		json.NewDecoder(r.Body).Decode(&details)
+		body = details.ServiceID
+	})
+
+	req := httptest.NewRequest(http.MethodPut, "/v2/service_instances/instance/service_bindings/successor", strings.NewReader(`{"service_id": "service", "predecessor_binding_id": "binding"}`))
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	assert.Equal(t, "binding", predecessor)
+	assert.Equal(t, "service", body, "Expected request body to be preserved")
+
+	req = httptest.NewRequest(http.MethodPut, "/v2/service_instances/instance/service_bindings/binding", strings.NewReader(`{"service_id": "service"}`))
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	assert.Equal(t, "", predecessor)
+}
+
+func TestRotateBindingEndpoint(t *testing.T) {
+	broker, client, ctx := setupTest()
+
+	instanceID := "instance"
+	broker.Provision(ctx, instanceID, brokerapi.ProvisionDetails{
+		PlanID:    testPlanID,
+		ServiceID: testServiceID,
+	}, true)
+
+	broker.Bind(ctx, instanceID, "binding", brokerapi.BindDetails{
+		PlanID:    testPlanID,
+		ServiceID: testServiceID,
+	}, true)
+
+	oldPassword := client.Users["binding"].Password
+
+	router := mux.NewRouter()
+	AttachAdminRoutes(router, broker)
+
+	rotate := func(bindingID string) *httptest.ResponseRecorder {
+		body := `{"service_id": "` + testServiceID + `", "plan_id": "` + testPlanID + `"}`
+		req := httptest.NewRequest(http.MethodPost, "/admin/v2/service_instances/instance/service_bindings/"+bindingID+"/rotate", strings.NewReader(body))
+		req = req.WithContext(ctx)
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := rotate("binding")
+	if !assert.Equal(t, http.StatusOK, w.Code) {
+		return
+	}
+
+	var response struct {
+		Credentials ConnectionDetails `json:"credentials"`
+	}
+
+	json.NewDecoder(w.Body).Decode(&response)
+	assert.Equal(t, "binding", response.Credentials.Username)
+	assert.NotEqual(t, oldPassword, response.Credentials.Password)
+	assert.Equal(t, response.Credentials.Password, client.Users["binding"].Password)
+
+	w = rotate("missing")
+	assert.Equal(t, http.StatusGone, w.Code)
+}
@@ -0,0 +1,286 @@
+package broker
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/mongodb/mongodb-atlas-service-broker/pkg/atlas"
+)
+
+// instanceRouteTTL is how long the Atlas API key an instance was found with
+// is cached before the instance is looked up again.
+const instanceRouteTTL = 10 * time.Minute
+
+// Route maps platform contexts to an Atlas API key. Every field is a pattern
+// as accepted by path.Match, e.g. "team-a-*", and empty fields match any
+// value. The first matching route is used.
+type Route struct {
+	Platform         string `json:"platform,omitempty"`
+	OrganizationGUID string `json:"organizationGuid,omitempty"`
+	OrganizationName string `json:"organizationName,omitempty"`
+	SpaceGUID        string `json:"spaceGuid,omitempty"`
+	SpaceName        string `json:"spaceName,omitempty"`
+	Namespace        string `json:"namespace,omitempty"`
+	ClusterID        string `json:"clusterId,omitempty"`
+
+	// Atlas is the name of the Atlas API key used for matching requests.
+	Atlas string `json:"atlas"`
+}
+
+// routeField is a pattern of a route together with the context value it is
+// matched against.
+type routeField struct {
+	pattern string
+	value   string
+}
+
+// fields returns the patterns of the route together with the values of the
+// context they are matched against.
+func (r Route) fields(context platformContext) []routeField {
+	return []routeField{
+		{r.Platform, context.Platform},
+		{r.OrganizationGUID, context.OrganizationGUID},
+		{r.OrganizationName, context.OrganizationName},
+		{r.SpaceGUID, context.SpaceGUID},
+		{r.SpaceName, context.SpaceName},
+		{r.Namespace, context.Namespace},
+		{r.ClusterID, context.ClusterID},
+	}
+}
+
+// validate makes sure all patterns of the route are well-formed.
+func (r Route) validate() error {
+	for _, field := range r.fields(platformContext{}) {
+		if _, err := path.Match(field.pattern, ""); err != nil {
+			return fmt.Errorf("invalid pattern %q", field.pattern)
+		}
+	}
+
+	return nil
+}
+
+// matches checks if all patterns of the route match the context.
+func (r Route) matches(context platformContext) bool {
+	for _, field := range r.fields(context) {
+		if field.pattern == "" {
+			continue
+		}
+
+		if matched, _ := path.Match(field.pattern, field.value); !matched {
+			return false
+		}
+	}
+
+	return true
+}
+
+// router chooses the Atlas API key for requests of platforms which aren't
+// mapped to a single key. Only provision, update, and bind requests carry a
+// context, other requests are routed to the project the instance was found
+// in. Platforms only ever use the routes for their own platform.
+type router struct {
+	baseURL     string
+	credentials *Credentials
+
+	// instances caches which Atlas API key each instance was routed to as
+	// an instanceRoute.
+	instances sync.Map
+}
+
+// instanceRoute is a cached route of an instance.
+type instanceRoute struct {
+	atlas   string
+	expires time.Time
+}
+
+// route will find the name of the Atlas API key a request of the given
+// platform should use. Returns false if the request carries a context which
+// isn't mapped to any of the platform's routes. An error is returned if the
+// project of the instance couldn't be determined as Atlas couldn't be
+// reached.
+func (r *router) route(req *http.Request, platformName string) (string, platformContext, bool, error) {
+	platform, hasContext := requestContext(req)
+	instanceID := mux.Vars(req)["instance_id"]
+	routes := r.routes(platformName)
+
+	if hasContext {
+		// Contexts claiming to be from another platform are rejected.
+		if platform.Platform != platformName {
+			return "", platform, false, nil
+		}
+
+		for _, route := range routes {
+			if route.matches(platform) {
+				if instanceID != "" {
+					r.remember(instanceID, route.Atlas)
+				}
+
+				return route.Atlas, platform, true, nil
+			}
+		}
+
+		return "", platform, false, nil
+	}
+
+	candidates := routeCandidates(routes)
+	if len(candidates) == 0 {
+		return "", platform, false, nil
+	}
+
+	// Requests such as fetching the catalog aren't tied to a project.
+	if instanceID == "" {
+		return candidates[0], platform, true, nil
+	}
+
+	// Cached routes of other platforms aren't used, the instance is looked
+	// up in the platform's own projects instead.
+	if cached, ok := r.instances.Load(instanceID); ok {
+		route := cached.(instanceRoute)
+		if !time.Now().Before(route.expires) {
+			r.forget(instanceID)
+		} else if containsString(candidates, route.atlas) {
+			return route.atlas, platform, true, nil
+		}
+	}
+
+	for _, name := range candidates {
+		ctx := contextWithAtlasCredentials(req.Context(), r.baseURL, r.credentials.Atlas[name])
+		exists, err := instanceExists(ctx, instanceID)
+		if err != nil {
+			return "", platform, true, err
+		}
+
+		if exists {
+			r.remember(instanceID, name)
+			return name, platform, true, nil
+		}
+	}
+
+	// The instance doesn't exist in any project of the platform. Any of its
+	// API keys will lead to the broker reporting it as gone.
+	return candidates[0], platform, true, nil
+}
+
+// remember caches the Atlas API key of an instance.
+func (r *router) remember(instanceID string, name string) {
+	r.instances.Store(instanceID, instanceRoute{
+		atlas:   name,
+		expires: time.Now().Add(instanceRouteTTL),
+	})
+}
+
+// forget removes the cached Atlas API key of an instance, e.g. once it has
+// been deprovisioned.
+func (r *router) forget(instanceID string) {
+	r.instances.Delete(instanceID)
+}
+
+// routes returns the routes which apply to requests of a platform. Routes
+// without a platform pattern apply to every platform.
+func (r *router) routes(platformName string) []Route {
+	routes := []Route{}
+	for _, route := range r.credentials.Routes {
+		if matched, _ := path.Match(route.Platform, platformName); route.Platform == "" || matched {
+			routes = append(routes, route)
+		}
+	}
+
+	return routes
+}
+
+// routeCandidates returns the names of all Atlas API keys used by the routes
+// in the order they appear.
+func routeCandidates(routes []Route) []string {
+	names := []string{}
+	seen := map[string]bool{}
+
+	for _, route := range routes {
+		if !seen[route.Atlas] {
+			seen[route.Atlas] = true
+			names = append(names, route.Atlas)
+		}
+	}
+
+	return names
+}
+
+// peekBody will read the body of a request without consuming it so it can
+// still be parsed by the handler. Returns nil if the body can't be read.
+func peekBody(req *http.Request) []byte {
+	if req.Body == nil {
+		return nil
+	}
+
+	body, err := ioutil.ReadAll(req.Body)
+	req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return nil
+	}
+
+	return body
+}
+
+// requestContext will parse the platform context from the body of a request
+// without consuming it. Returns false if the request has no context.
+func requestContext(req *http.Request) (platformContext, bool) {
+	var platform platformContext
+
+	body := peekBody(req)
+	if len(body) == 0 {
+		return platform, false
+	}
+
+	var details struct {
+		RawContext json.RawMessage `json:"context"`
+	}
+
+	if err := json.Unmarshal(body, &details); err != nil || len(details.RawContext) == 0 {
+		return platform, false
+	}
+
+	platform, err := platformContextFromRaw(details.RawContext)
+	return platform, err == nil
+}
+
+// instanceExists checks if an instance of any service exists in the project
+// of the Atlas client in the context. Only errors telling that the instance
+// wasn't found count as absent, any other error is returned.
+func instanceExists(ctx context.Context, instanceID string) (bool, error) {
+	client, err := instanceClient(ctx, instanceID)
+	if err == atlas.ErrProjectNotFound {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, err
+	}
+
+	name := NormalizeClusterName(instanceID)
+	checks := []func() error{
+		func() error { _, err := client.GetCluster(name); return err },
+		func() error { _, err := client.GetServerlessInstance(name); return err },
+		func() error { _, err := client.GetFederatedDatabase(name); return err },
+	}
+
+	for _, check := range checks {
+		err := check()
+		if err == nil {
+			return true, nil
+		}
+
+		notFound := err == atlas.ErrClusterNotFound || err == atlas.ErrServerlessInstanceNotFound || err == atlas.ErrFederatedDatabaseNotFound
+		if !notFound {
+			return false, err
+		}
+	}
+
+	return false, nil
+}
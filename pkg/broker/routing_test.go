@@ -0,0 +1,211 @@
+package broker
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/mongodb/mongodb-atlas-service-broker/pkg/atlas"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+const testRoutingCredentials = `{
+	"atlas": {
+		"team-a": {"groupId": "group-a", "publicKey": "public-key", "privateKey": "private-key"},
+		"team-b": {"groupId": "group-b", "publicKey": "public-key", "privateKey": "private-key"}
+	},
+	"platforms": [
+		{"token": "cf-token", "platform": "cloudfoundry"},
+		{"token": "k8s-token", "platform": "kubernetes"}
+	],
+	"routes": [
+		{"platform": "cloudfoundry", "organizationName": "team-a-*", "atlas": "team-a"},
+		{"platform": "kubernetes", "namespace": "team-b", "atlas": "team-b"},
+		{"platform": "cloudfoundry", "organizationGuid": "org-b", "spaceName": "*", "atlas": "team-b"}
+	]
+}`
+
+func TestRouteMatches(t *testing.T) {
+	route := Route{Platform: "cloudfoundry", OrganizationName: "team-a-*"}
+
+	assert.True(t, route.matches(platformContext{Platform: "cloudfoundry", OrganizationName: "team-a-dev"}))
+	assert.False(t, route.matches(platformContext{Platform: "cloudfoundry", OrganizationName: "team-b-dev"}))
+	assert.False(t, route.matches(platformContext{Platform: "kubernetes", OrganizationName: "team-a-dev"}))
+	assert.True(t, Route{}.matches(platformContext{Platform: "kubernetes"}))
+}
+
+func TestParseInvalidRoutes(t *testing.T) {
+	invalidCredentials := []string{
+		// Platform without an Atlas API key and no routes.
+		`{"atlas": {"a": {"groupId": "g", "publicKey": "p", "privateKey": "k"}}, "platforms": [{"token": "t", "platform": "kubernetes"}]}`,
+		// Routed platform without a platform name.
+		`{"atlas": {"a": {"groupId": "g", "publicKey": "p", "privateKey": "k"}}, "platforms": [{"token": "t"}], "routes": [{"atlas": "a"}]}`,
+		// Route to an unknown Atlas API key.
+		`{"atlas": {"a": {"groupId": "g", "publicKey": "p", "privateKey": "k"}}, "platforms": [{"token": "t"}], "routes": [{"atlas": "b"}]}`,
+		// Malformed pattern.
+		`{"atlas": {"a": {"groupId": "g", "publicKey": "p", "privateKey": "k"}}, "platforms": [{"token": "t"}], "routes": [{"namespace": "[", "atlas": "a"}]}`,
+	}
+
+	for _, credentials := range invalidCredentials {
+		_, err := ParseCredentials([]byte(credentials))
+		assert.Errorf(t, err, "Expected credentials %s to be rejected", credentials)
+	}
+}
+
+func TestCredentialsMiddlewareRouting(t *testing.T) {
+	credentials, err := ParseCredentials([]byte(testRoutingCredentials))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	middleware := CredentialsMiddleware(zap.NewNop().Sugar(), "http://baseURL", credentials)
+
+	var client *atlas.HTTPClient
+	var body string
+	testHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		client, _ = r.Context().Value(ContextKeyAtlasClient).(*atlas.HTTPClient)
+		data, _ := ioutil.ReadAll(r.Body)
+		body = string(data)
+	})
+
+	serve := func(token string, context string) int {
+		client = nil
+		body = ""
+
+		data := `{"service_id": "service", "context": ` + context + `}`
+		req, _ := http.NewRequest("PUT", "http://test/v2/service_instances/instance", strings.NewReader(data))
+		req.Header.Set("Authorization", "Bearer "+token)
+		req = mux.SetURLVars(req, map[string]string{"instance_id": "instance"})
+
+		w := httptest.NewRecorder()
+		middleware(testHandler).ServeHTTP(w, req)
+
+		if w.Result().StatusCode == http.StatusOK {
+			assert.Equal(t, data, body, "Expected request body to be preserved")
+		}
+
+		return w.Result().StatusCode
+	}
+
+	status := serve("cf-token", `{"platform": "cloudfoundry", "organization_name": "team-a-dev"}`)
+	assert.Equal(t, http.StatusOK, status)
+	if assert.NotNil(t, client) {
+		assert.Equal(t, "group-a", client.GroupID)
+	}
+
+	status = serve("k8s-token", `{"platform": "kubernetes", "namespace": "team-b"}`)
+	assert.Equal(t, http.StatusOK, status)
+	if assert.NotNil(t, client) {
+		assert.Equal(t, "group-b", client.GroupID)
+	}
+
+	status = serve("cf-token", `{"platform": "cloudfoundry", "organization_guid": "org-b", "space_guid": "space"}`)
+	assert.Equal(t, http.StatusOK, status)
+	if assert.NotNil(t, client) {
+		assert.Equal(t, "group-b", client.GroupID)
+	}
+
+	// Contexts without a route are rejected.
+	status = serve("k8s-token", `{"platform": "kubernetes", "namespace": "team-c"}`)
+	assert.Equal(t, http.StatusForbidden, status)
+	assert.Nil(t, client)
+
+	// Platforms can't use the routes of other platforms.
+	status = serve("k8s-token", `{"platform": "cloudfoundry", "organization_name": "team-a-dev"}`)
+	assert.Equal(t, http.StatusForbidden, status)
+	assert.Nil(t, client)
+}
+
+func TestRouteWithoutContext(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		// If auth header is missing we return 401 to trigger the digest process
+		if len(req.Header["Authorization"]) == 0 {
+			rw.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		switch req.URL.Path {
+		case "/api/atlas/v1.0/groups/group-a/clusters/cf-instance", "/api/atlas/v1.0/groups/group-b/clusters/instance":
+			data, _ := json.Marshal(atlas.Cluster{Name: path.Base(req.URL.Path)})
+			rw.Write(data)
+			return
+		}
+
+		rw.WriteHeader(http.StatusNotFound)
+		data, _ := json.Marshal(struct {
+			Code string `json:"errorCode"`
+		}{"CLUSTER_NOT_FOUND"})
+		rw.Write(data)
+	}))
+	defer server.Close()
+
+	credentials, err := ParseCredentials([]byte(testRoutingCredentials))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	router := &router{
+		baseURL:     server.URL,
+		credentials: credentials,
+	}
+
+	routeFor := func(platformName string, instanceID string) (string, error) {
+		req, _ := http.NewRequest("DELETE", "http://test/v2/service_instances/"+instanceID, nil)
+		req = mux.SetURLVars(req, map[string]string{"instance_id": instanceID})
+
+		name, _, ok, err := router.route(req, platformName)
+		assert.True(t, ok)
+		return name, err
+	}
+
+	route := func(instanceID string) (string, error) {
+		return routeFor("cloudfoundry", instanceID)
+	}
+
+	// The instance is found in the project of the second API key.
+	name, err := route("instance")
+	assert.NoError(t, err)
+	assert.Equal(t, "team-b", name)
+
+	// Instances which aren't found in any project fall back to the first
+	// API key.
+	name, err = route("unknown")
+	assert.NoError(t, err)
+	assert.Equal(t, "team-a", name)
+
+	// Instances are only looked up in the projects of the platform's routes,
+	// even if another platform's request cached them.
+	name, err = route("cf-instance")
+	assert.NoError(t, err)
+	assert.Equal(t, "team-a", name)
+
+	name, err = routeFor("kubernetes", "cf-instance")
+	assert.NoError(t, err)
+	assert.Equal(t, "team-b", name)
+
+	// Found instances are cached while Atlas can't be reached for the
+	// others.
+	server.Close()
+	name, err = route("instance")
+	assert.NoError(t, err)
+	assert.Equal(t, "team-b", name)
+
+	_, err = route("other")
+	assert.Error(t, err)
+
+	// Expired and forgotten routes are looked up again.
+	router.instances.Store("expired", instanceRoute{atlas: "team-b", expires: time.Now().Add(-time.Second)})
+	_, err = route("expired")
+	assert.Error(t, err)
+
+	router.forget("instance")
+	_, err = route("instance")
+	assert.Error(t, err)
+}
@@ -0,0 +1,159 @@
+package broker
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/mongodb/mongodb-atlas-service-broker/pkg/atlas"
+	"github.com/pivotal-cf/brokerapi/domain/apiresponses"
+)
+
+// The labels used to store a schedule on its cluster. Keeping the schedule in
+// Atlas lets the scheduler discover it without any state in the broker.
+const (
+	LabelSchedulePause    = "aosb-schedule-pause"
+	LabelScheduleResume   = "aosb-schedule-resume"
+	LabelScheduleTimezone = "aosb-schedule-timezone"
+)
+
+// schedule describes when a cluster should automatically be paused and
+// resumed, e.g. to save costs during nights and weekends. Both times are cron
+// expressions evaluated in the timezone.
+type schedule struct {
+	Pause    string `json:"pause,omitempty"`
+	Resume   string `json:"resume,omitempty"`
+	Timezone string `json:"timezone,omitempty"`
+}
+
+// scheduleFromParams will parse the pause schedule passed as "schedule" in
+// the raw parameters. Returns nil if no schedule was specified. An empty
+// schedule removes an existing one.
+func scheduleFromParams(rawParams []byte) (*schedule, error) {
+	params := struct {
+		Schedule *schedule `json:"schedule"`
+	}{}
+
+	if len(rawParams) > 0 {
+		err := json.Unmarshal(rawParams, &params)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if params.Schedule == nil || params.Schedule.isEmpty() {
+		return params.Schedule, nil
+	}
+
+	if params.Schedule.Pause == "" || params.Schedule.Resume == "" {
+		return nil, invalidScheduleError(errors.New("both pause and resume must be specified"))
+	}
+
+	_, _, _, err := params.Schedule.parse()
+	if err != nil {
+		return nil, invalidScheduleError(err)
+	}
+
+	return params.Schedule, nil
+}
+
+// scheduleFromLabels will read a schedule from the labels of a cluster.
+// Returns nil if the cluster has no schedule.
+func scheduleFromLabels(labels []atlas.Label) *schedule {
+	s := schedule{}
+
+	for _, label := range labels {
+		switch label.Key {
+		case LabelSchedulePause:
+			s.Pause = label.Value
+		case LabelScheduleResume:
+			s.Resume = label.Value
+		case LabelScheduleTimezone:
+			s.Timezone = label.Value
+		}
+	}
+
+	if s.isEmpty() {
+		return nil
+	}
+
+	return &s
+}
+
+// isEmpty checks if the schedule neither pauses nor resumes the cluster.
+func (s schedule) isEmpty() bool {
+	return s.Pause == "" && s.Resume == ""
+}
+
+// parse will parse the cron expressions and timezone of the schedule. UTC is
+// used if no timezone is specified.
+func (s schedule) parse() (pause *cronSchedule, resume *cronSchedule, location *time.Location, err error) {
+	pause, err = parseCron(s.Pause)
+	if err != nil {
+		return
+	}
+
+	resume, err = parseCron(s.Resume)
+	if err != nil {
+		return
+	}
+
+	location, err = time.LoadLocation(s.Timezone)
+	if err != nil {
+		err = fmt.Errorf("unknown timezone %q", s.Timezone)
+	}
+
+	return
+}
+
+// applyScheduleLabels will replace any schedule labels in a list of labels
+// with the ones describing the schedule. Other labels are kept as is.
+func applyScheduleLabels(labels []atlas.Label, s schedule) []atlas.Label {
+	result := []atlas.Label{}
+	for _, label := range labels {
+		if !strings.HasPrefix(label.Key, "aosb-schedule-") {
+			result = append(result, label)
+		}
+	}
+
+	if s.isEmpty() {
+		return result
+	}
+
+	result = append(result,
+		atlas.Label{Key: LabelSchedulePause, Value: s.Pause},
+		atlas.Label{Key: LabelScheduleResume, Value: s.Resume},
+	)
+
+	if s.Timezone != "" {
+		result = append(result, atlas.Label{Key: LabelScheduleTimezone, Value: s.Timezone})
+	}
+
+	return result
+}
+
+// checkSchedulable makes sure a schedule can be applied to a cluster. Atlas
+// can't pause tenant clusters such as M2 and M5.
+func checkSchedulable(cluster *atlas.Cluster) error {
+	settings := cluster.ProviderSettings
+	if settings == nil {
+		return nil
+	}
+
+	switch {
+	case settings.ProviderName == "TENANT",
+		settings.InstanceSizeName == "M0",
+		settings.InstanceSizeName == InstanceSizeNameM2,
+		settings.InstanceSizeName == InstanceSizeNameM5:
+		return invalidScheduleError(errors.New("tenant clusters cannot be paused and therefore not be scheduled"))
+	}
+
+	return nil
+}
+
+func invalidScheduleError(err error) error {
+	return apiresponses.NewFailureResponse(err, http.StatusBadRequest, "invalid-schedule")
+}
@@ -0,0 +1,178 @@
+package broker
+
+import (
+	"time"
+
+	"github.com/mongodb/mongodb-atlas-service-broker/pkg/atlas"
+	"go.uber.org/zap"
+)
+
+// The actions taken by the scheduler.
+const (
+	ScheduleActionPause  = "pause"
+	ScheduleActionResume = "resume"
+)
+
+// schedulerInterval is how often the scheduler checks for clusters which
+// need to be paused or resumed. Cron expressions have minute precision.
+const schedulerInterval = time.Minute
+
+// maximumSchedulerCatchUp limits how far back the scheduler looks for missed
+// actions, e.g. when checks have been delayed.
+const maximumSchedulerCatchUp = 24 * time.Hour
+
+// Scheduler is a background worker which pauses and resumes clusters
+// according to the schedule stored in their labels. It uses its own Atlas
+// clients as there are no platform requests to take credentials from.
+type Scheduler struct {
+	logger *zap.SugaredLogger
+
+	// clients are the Atlas clients for every project the scheduler manages
+	// keyed by group ID.
+	clients map[string]atlas.Client
+
+	// lastRun is the time up to which actions have been taken.
+	lastRun time.Time
+
+	// pending are the actions which haven't been applied yet, e.g. as the
+	// cluster was busy, keyed by group ID and cluster name. They are retried
+	// on every check until a later action of the schedule replaces them.
+	pending map[string]map[string]string
+}
+
+// NewScheduler creates a new Scheduler managing the projects of the given
+// Atlas clients keyed by their group ID.
+func NewScheduler(logger *zap.SugaredLogger, clients map[string]atlas.Client) *Scheduler {
+	return &Scheduler{
+		logger:  logger,
+		clients: clients,
+		pending: map[string]map[string]string{},
+	}
+}
+
+// Run will check the schedules of all clusters every minute until the stop
+// channel is closed. It is meant to be run in its own goroutine. Schedules
+// which fired before the scheduler started aren't replayed, as clusters might
+// have been paused or resumed manually since.
+func (s *Scheduler) Run(stop <-chan struct{}) {
+	s.logger.Infow("Starting scheduler", "projects", len(s.clients))
+
+	ticker := time.NewTicker(schedulerInterval)
+	defer ticker.Stop()
+
+	s.lastRun = time.Now().Add(-schedulerInterval)
+	s.Check(time.Now())
+
+	for {
+		select {
+		case <-stop:
+			s.logger.Info("Stopping scheduler")
+			return
+		case now := <-ticker.C:
+			s.Check(now)
+		}
+	}
+}
+
+// Check will pause or resume all clusters whose schedule fired since the
+// last check and retry actions which are still pending.
+func (s *Scheduler) Check(now time.Time) {
+	from := s.lastRun
+	if from.IsZero() || now.Sub(from) > maximumSchedulerCatchUp {
+		from = now.Add(-maximumSchedulerCatchUp)
+	}
+
+	for groupID, client := range s.clients {
+		clusters, err := client.ListClusters()
+		if err != nil {
+			s.logger.Errorw("Failed to list clusters for scheduling", "error", err, "group_id", groupID)
+			continue
+		}
+
+		// Pending actions of clusters which are gone or no longer
+		// scheduled are dropped.
+		pending := map[string]string{}
+		for _, cluster := range clusters {
+			action := s.checkCluster(groupID, client, cluster, s.pending[groupID][cluster.Name], from, now)
+			if action != "" {
+				pending[cluster.Name] = action
+			}
+		}
+
+		s.pending[groupID] = pending
+	}
+
+	s.lastRun = now
+}
+
+// checkCluster will pause or resume a single cluster if its schedule fired in
+// the interval (from, to] or an earlier action is still pending. Returns the
+// action which is still pending afterwards, if any.
+func (s *Scheduler) checkCluster(groupID string, client atlas.Client, cluster atlas.Cluster, pending string, from time.Time, to time.Time) string {
+	clusterSchedule := scheduleFromLabels(cluster.Labels)
+	if clusterSchedule == nil {
+		return ""
+	}
+
+	pause, resume, location, err := clusterSchedule.parse()
+	if err != nil {
+		s.logger.Errorw("Skipping cluster with invalid schedule", "error", err, "group_id", groupID, "cluster", cluster.Name, "schedule", clusterSchedule)
+		return ""
+	}
+
+	// If both fired the one which fired last wins. Actions which fired
+	// replace any pending one.
+	pausedAt, shouldPause := pause.lastFiring(from.In(location), to.In(location))
+	resumedAt, shouldResume := resume.lastFiring(from.In(location), to.In(location))
+
+	action := pending
+	switch {
+	case shouldPause && (!shouldResume || pausedAt.After(resumedAt)):
+		action = ScheduleActionPause
+	case shouldResume:
+		action = ScheduleActionResume
+	}
+
+	if action == "" {
+		return ""
+	}
+
+	paused := action == ScheduleActionPause
+	if cluster.IsPaused() == paused {
+		s.audit(groupID, cluster, action, "already-done", nil)
+		return ""
+	}
+
+	// Atlas rejects changes to clusters which are busy so the action is
+	// retried on the next check.
+	if cluster.StateName != atlas.ClusterStateIdle {
+		s.audit(groupID, cluster, action, "deferred", nil)
+		return action
+	}
+
+	_, err = client.UpdateCluster(atlas.Cluster{
+		Name:   cluster.Name,
+		Paused: &paused,
+	})
+
+	if err != nil {
+		s.audit(groupID, cluster, action, "failed", err)
+		return action
+	}
+
+	s.audit(groupID, cluster, action, "succeeded", nil)
+	return ""
+}
+
+// audit will log an action taken by the scheduler together with its result.
+// All entries are marked with "audit" to make them easy to find.
+func (s *Scheduler) audit(groupID string, cluster atlas.Cluster, action string, result string, err error) {
+	fields := []interface{}{"audit", true, "group_id", groupID, "cluster", cluster.Name, "instance_id", instanceIDFromCluster(cluster), "action", action, "result", result}
+
+	if err != nil {
+		s.logger.Errorw("Scheduled cluster action failed", append(fields, "error", err)...)
+		return
+	}
+
+	s.logger.Infow("Scheduled cluster action", fields...)
+}
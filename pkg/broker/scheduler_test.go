@@ -0,0 +1,192 @@
+package broker
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mongodb/mongodb-atlas-service-broker/pkg/atlas"
+	"github.com/pivotal-cf/brokerapi"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestProvisionSchedule(t *testing.T) {
+	broker, client, ctx := setupTest()
+
+	instanceID := "instance"
+	_, err := broker.Provision(ctx, instanceID, brokerapi.ProvisionDetails{
+		PlanID:        testPlanID,
+		ServiceID:     testServiceID,
+		RawParameters: []byte(`{"schedule": {"pause": "0 20 * * 1-5", "resume": "0 7 * * 1-5", "timezone": "Europe/Berlin"}}`),
+	}, true)
+
+	assert.NoError(t, err)
+
+	labels := client.Clusters[instanceID].Labels
+	assert.Equal(t, "0 20 * * 1-5", labelValue(labels, LabelSchedulePause))
+	assert.Equal(t, "0 7 * * 1-5", labelValue(labels, LabelScheduleResume))
+	assert.Equal(t, "Europe/Berlin", labelValue(labels, LabelScheduleTimezone))
+}
+
+func TestUpdateScheduleKeepsLabels(t *testing.T) {
+	broker, client, ctx := setupTest()
+
+	instanceID := "instance"
+	broker.Provision(ctx, instanceID, brokerapi.ProvisionDetails{
+		PlanID:        testPlanID,
+		ServiceID:     testServiceID,
+		RawParameters: []byte(`{"cluster": {"labels": [{"key": "team", "value": "a"}]}, "schedule": {"pause": "0 20 * * *", "resume": "0 7 * * *"}}`),
+	}, true)
+
+	// An empty schedule removes the schedule but not the other labels.
+	_, err := broker.Update(ctx, instanceID, brokerapi.UpdateDetails{
+		ServiceID:     testServiceID,
+		RawParameters: []byte(`{"schedule": {}}`),
+	}, true)
+
+	assert.NoError(t, err)
+	labels := client.Clusters[instanceID].Labels
+	assert.Equal(t, "a", labelValue(labels, "team"))
+	assert.Nil(t, scheduleFromLabels(labels), "Expected schedule to be removed")
+}
+
+func TestInvalidSchedule(t *testing.T) {
+	broker, client, ctx := setupTest()
+
+	invalidParams := []string{
+		`{"schedule": {"pause": "0 20 * * *"}}`,
+		`{"schedule": {"pause": "0 20 * *", "resume": "0 7 * * *"}}`,
+		`{"schedule": {"pause": "0 20 * * *", "resume": "0 7 * * *", "timezone": "Mars/Olympus_Mons"}}`,
+		// Tenant clusters can't be paused.
+		`{"cluster": {"providerSettings": {"instanceSizeName": "M2"}}, "schedule": {"pause": "0 20 * * *", "resume": "0 7 * * *"}}`,
+	}
+
+	for _, params := range invalidParams {
+		_, err := broker.Provision(ctx, "instance", brokerapi.ProvisionDetails{
+			PlanID:        testPlanID,
+			ServiceID:     testServiceID,
+			RawParameters: []byte(params),
+		}, true)
+
+		assert.Errorf(t, err, "Expected params %s to be rejected", params)
+	}
+
+	assert.Len(t, client.Clusters, 0, "Expected no clusters to be created")
+}
+
+func TestSchedulerPausesAndResumes(t *testing.T) {
+	_, client, _ := setupTest()
+
+	client.Clusters["scheduled"] = &atlas.Cluster{
+		Name:      "scheduled",
+		StateName: atlas.ClusterStateIdle,
+		Labels: applyScheduleLabels(nil, schedule{
+			Pause:    "0 20 * * 1-5",
+			Resume:   "0 7 * * 1-5",
+			Timezone: "Europe/Berlin",
+		}),
+	}
+	client.Clusters["unscheduled"] = &atlas.Cluster{
+		Name:      "unscheduled",
+		StateName: atlas.ClusterStateIdle,
+	}
+
+	location, err := time.LoadLocation("Europe/Berlin")
+	assert.NoError(t, err)
+
+	scheduler := NewScheduler(zap.NewNop().Sugar(), map[string]atlas.Client{"group": client})
+
+	// Monday, 6 January 2020 at 20:00 in Berlin.
+	evening := time.Date(2020, time.January, 6, 20, 0, 0, 0, location)
+	scheduler.Check(evening.Add(-time.Minute))
+	assert.False(t, client.Clusters["scheduled"].IsPaused(), "Expected cluster to not be paused before the schedule fires")
+
+	scheduler.Check(evening)
+	assert.True(t, client.Clusters["scheduled"].IsPaused(), "Expected cluster to be paused once the schedule fires")
+	assert.False(t, client.Clusters["unscheduled"].IsPaused(), "Expected clusters without schedule to not be touched")
+
+	// The mock replaces the cluster on update so the labels need to be
+	// restored.
+	client.Clusters["scheduled"].StateName = atlas.ClusterStateIdle
+	client.Clusters["scheduled"].Labels = applyScheduleLabels(nil, schedule{
+		Pause:    "0 20 * * 1-5",
+		Resume:   "0 7 * * 1-5",
+		Timezone: "Europe/Berlin",
+	})
+
+	// The scheduler catches up on actions it missed between checks.
+	scheduler.Check(evening.Add(12 * time.Hour))
+	assert.False(t, client.Clusters["scheduled"].IsPaused(), "Expected cluster to be resumed in the morning")
+}
+
+func TestSchedulerSkipsBusyClusters(t *testing.T) {
+	_, client, _ := setupTest()
+
+	client.Clusters["scheduled"] = &atlas.Cluster{
+		Name:      "scheduled",
+		StateName: atlas.ClusterStateUpdating,
+		Labels:    applyScheduleLabels(nil, schedule{Pause: "0 20 * * *", Resume: "0 7 * * *"}),
+	}
+
+	scheduler := NewScheduler(zap.NewNop().Sugar(), map[string]atlas.Client{"group": client})
+
+	evening := time.Date(2020, time.January, 6, 20, 0, 0, 0, time.UTC)
+	scheduler.Check(evening)
+
+	assert.False(t, client.Clusters["scheduled"].IsPaused())
+	assert.Equal(t, atlas.ClusterStateUpdating, client.Clusters["scheduled"].StateName)
+
+	// The action stays pending until the cluster isn't busy anymore.
+	client.Clusters["scheduled"].StateName = atlas.ClusterStateIdle
+	scheduler.Check(evening.Add(5 * time.Minute))
+	assert.True(t, client.Clusters["scheduled"].IsPaused(), "Expected pending pause to be retried")
+}
+
+// failingUpdateClient fails the given number of cluster updates.
+type failingUpdateClient struct {
+	MockAtlasClient
+	failures *int
+}
+
+func (c failingUpdateClient) UpdateCluster(cluster atlas.Cluster) (*atlas.Cluster, error) {
+	if *c.failures > 0 {
+		*c.failures--
+		return nil, errors.New("atlas unavailable")
+	}
+
+	return c.MockAtlasClient.UpdateCluster(cluster)
+}
+
+func TestSchedulerRetriesFailedActions(t *testing.T) {
+	_, client, _ := setupTest()
+
+	labels := applyScheduleLabels(nil, schedule{Pause: "0 20 * * *", Resume: "0 7 * * *"})
+	client.Clusters["scheduled"] = &atlas.Cluster{
+		Name:      "scheduled",
+		StateName: atlas.ClusterStateIdle,
+		Labels:    labels,
+	}
+
+	failures := 1
+	scheduler := NewScheduler(zap.NewNop().Sugar(), map[string]atlas.Client{
+		"group": failingUpdateClient{client, &failures},
+	})
+
+	evening := time.Date(2020, time.January, 6, 20, 0, 0, 0, time.UTC)
+	scheduler.Check(evening)
+	assert.False(t, client.Clusters["scheduled"].IsPaused())
+
+	scheduler.Check(evening.Add(time.Minute))
+	assert.True(t, client.Clusters["scheduled"].IsPaused(), "Expected failed pause to be retried")
+
+	// A later action of the schedule replaces a pending one.
+	client.Clusters["scheduled"].Labels = labels
+	client.Clusters["scheduled"].StateName = atlas.ClusterStateUpdating
+	morning := evening.Add(11 * time.Hour)
+	scheduler.Check(morning)
+
+	client.Clusters["scheduled"].StateName = atlas.ClusterStateIdle
+	scheduler.Check(morning.Add(time.Minute))
+	assert.False(t, client.Clusters["scheduled"].IsPaused(), "Expected cluster to be resumed")
+}
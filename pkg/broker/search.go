@@ -0,0 +1,274 @@
+package broker
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/mongodb/mongodb-atlas-service-broker/pkg/atlas"
+	"github.com/pivotal-cf/brokerapi"
+	"github.com/pivotal-cf/brokerapi/domain/apiresponses"
+)
+
+// LabelSearchIndexPrefix is the prefix of the cluster labels tracking the
+// search indexes created by the broker. The value of each label is the
+// namespace and name of an index, the key is derived from it as keys must be
+// unique.
+const LabelSearchIndexPrefix = "aosb-search-index-"
+
+// searchNamespace identifies the collection a search index belongs to.
+type searchNamespace struct {
+	Database   string
+	Collection string
+}
+
+// searchIndexesFromParams will parse the search indexes passed as
+// "searchIndexes" in the params. Every index needs a name, database and
+// collection, and names must be unique per collection.
+func searchIndexesFromParams(rawParams []byte) ([]atlas.SearchIndex, error) {
+	params := struct {
+		SearchIndexes []atlas.SearchIndex `json:"searchIndexes"`
+	}{}
+
+	if len(rawParams) > 0 {
+		err := json.Unmarshal(rawParams, &params)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	seen := map[string]bool{}
+	for i, index := range params.SearchIndexes {
+		if index.Name == "" || index.Database == "" || index.CollectionName == "" {
+			return nil, invalidSearchIndexesError(fmt.Errorf("search index %d is missing a name, database or collectionName", i))
+		}
+
+		key := index.Database + "." + index.CollectionName + "/" + index.Name
+		if seen[key] {
+			return nil, invalidSearchIndexesError(fmt.Errorf("duplicate search index %q on %s.%s", index.Name, index.Database, index.CollectionName))
+		}
+		seen[key] = true
+
+		// Read-only attributes are managed by Atlas.
+		params.SearchIndexes[i].IndexID = ""
+		params.SearchIndexes[i].Status = ""
+	}
+
+	return params.SearchIndexes, nil
+}
+
+// searchIndexKey identifies a search index by its collection and name.
+func searchIndexKey(index atlas.SearchIndex) string {
+	return index.Database + "." + index.CollectionName + "/" + index.Name
+}
+
+// managedSearchIndexes returns the keys of the search indexes created by the
+// broker as tracked in the cluster labels.
+func managedSearchIndexes(labels []atlas.Label) []string {
+	keys := []string{}
+	for _, label := range labels {
+		if strings.HasPrefix(label.Key, LabelSearchIndexPrefix) {
+			keys = append(keys, label.Value)
+		}
+	}
+
+	return keys
+}
+
+// replaceManagedSearchIndexes returns the keys of the search indexes created
+// by the broker once the desired indexes are in place. Indexes on collections
+// which aren't referenced by desired stay managed.
+func replaceManagedSearchIndexes(managed []string, desired []atlas.SearchIndex) []string {
+	referenced := map[string]bool{}
+	keys := []string{}
+	for _, index := range desired {
+		referenced[index.Database+"."+index.CollectionName] = true
+		keys = append(keys, searchIndexKey(index))
+	}
+
+	for _, key := range managed {
+		namespace := key
+		if i := strings.LastIndex(key, "/"); i >= 0 {
+			namespace = key[:i]
+		}
+
+		if !referenced[namespace] {
+			keys = append(keys, key)
+		}
+	}
+
+	return keys
+}
+
+// trackSearchIndexes will replace the labels tracking the search indexes
+// created by the broker with labels for the given keys.
+func trackSearchIndexes(labels []atlas.Label, keys []string) []atlas.Label {
+	result := []atlas.Label{}
+	for _, label := range labels {
+		if !strings.HasPrefix(label.Key, LabelSearchIndexPrefix) {
+			result = append(result, label)
+		}
+	}
+
+	for _, key := range keys {
+		hash := sha256.Sum256([]byte(key))
+		result = append(result, atlas.Label{
+			Key:   LabelSearchIndexPrefix + hex.EncodeToString(hash[:8]),
+			Value: key,
+		})
+	}
+
+	return result
+}
+
+// reconcileSearchIndexes will make the search indexes of every collection
+// referenced in desired match the desired indexes. Missing indexes are
+// created and changed indexes are updated. Indexes which aren't part of
+// desired are only deleted if the broker created them, as listed in managed,
+// so indexes created by users are kept. Collections which aren't referenced
+// are left alone.
+func reconcileSearchIndexes(client atlas.Client, clusterName string, desired []atlas.SearchIndex, managed []string) error {
+	for _, namespace := range searchNamespaces(desired) {
+		existing, err := client.ListSearchIndexes(clusterName, namespace.Database, namespace.Collection)
+		if err != nil {
+			return err
+		}
+
+		existingByName := map[string]atlas.SearchIndex{}
+		for _, index := range existing {
+			existingByName[index.Name] = index
+		}
+
+		for _, index := range desired {
+			if index.Database != namespace.Database || index.CollectionName != namespace.Collection {
+				continue
+			}
+
+			existingIndex, ok := existingByName[index.Name]
+			delete(existingByName, index.Name)
+
+			if !ok {
+				_, err = client.CreateSearchIndex(clusterName, index)
+			} else if searchIndexChanged(existingIndex, index) {
+				index.IndexID = existingIndex.IndexID
+				_, err = client.UpdateSearchIndex(clusterName, index)
+			}
+
+			if err != nil {
+				return err
+			}
+		}
+
+		// Any remaining indexes created by the broker are no longer wanted.
+		for _, index := range existingByName {
+			if !containsString(managed, searchIndexKey(index)) {
+				continue
+			}
+
+			err = client.DeleteSearchIndex(clusterName, index.IndexID)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// searchIndexesState will create any of the desired search indexes which don't
+// exist yet and determine the state of the operation from the build status of
+// the indexes. The operation is finished once all indexes are steady.
+func searchIndexesState(client atlas.Client, clusterName string, desired []atlas.SearchIndex) (brokerapi.LastOperationState, string, error) {
+	total := len(desired)
+	ready := 0
+
+	for _, namespace := range searchNamespaces(desired) {
+		existing, err := client.ListSearchIndexes(clusterName, namespace.Database, namespace.Collection)
+		if err != nil {
+			return brokerapi.Failed, "", err
+		}
+
+		for _, index := range desired {
+			if index.Database != namespace.Database || index.CollectionName != namespace.Collection {
+				continue
+			}
+
+			existingIndex := findSearchIndex(existing, index.Name)
+			if existingIndex == nil {
+				_, err = client.CreateSearchIndex(clusterName, index)
+				if err != nil {
+					return brokerapi.Failed, "", err
+				}
+
+				continue
+			}
+
+			switch existingIndex.Status {
+			case atlas.SearchIndexStatusSteady:
+				ready++
+			case atlas.SearchIndexStatusFailed:
+				description := fmt.Sprintf("Search index %q on %s.%s failed to build", index.Name, index.Database, index.CollectionName)
+				return brokerapi.Failed, description, nil
+			}
+		}
+	}
+
+	description := fmt.Sprintf("Building search indexes (%d/%d ready)", ready, total)
+	if ready == total {
+		return brokerapi.Succeeded, description, nil
+	}
+
+	return brokerapi.InProgress, description, nil
+}
+
+// searchNamespaces returns the distinct collections referenced by a list of
+// search indexes in the order they first appear.
+func searchNamespaces(indexes []atlas.SearchIndex) []searchNamespace {
+	var namespaces []searchNamespace
+	seen := map[searchNamespace]bool{}
+
+	for _, index := range indexes {
+		namespace := searchNamespace{Database: index.Database, Collection: index.CollectionName}
+		if !seen[namespace] {
+			seen[namespace] = true
+			namespaces = append(namespaces, namespace)
+		}
+	}
+
+	return namespaces
+}
+
+// findSearchIndex will find a search index by name.
+func findSearchIndex(indexes []atlas.SearchIndex, name string) *atlas.SearchIndex {
+	for i := range indexes {
+		if indexes[i].Name == name {
+			return &indexes[i]
+		}
+	}
+
+	return nil
+}
+
+// searchIndexChanged checks if an existing index differs from its desired
+// definition. Only attributes set in the desired definition are compared as
+// Atlas fills in defaults for the others. Updating an index causes it to be
+// rebuilt so unchanged indexes should be left alone.
+func searchIndexChanged(existing atlas.SearchIndex, desired atlas.SearchIndex) bool {
+	if desired.Analyzer != "" && desired.Analyzer != existing.Analyzer {
+		return true
+	}
+
+	if desired.SearchAnalyzer != "" && desired.SearchAnalyzer != existing.SearchAnalyzer {
+		return true
+	}
+
+	return desired.Mappings != nil && !reflect.DeepEqual(desired.Mappings, existing.Mappings)
+}
+
+func invalidSearchIndexesError(err error) error {
+	return apiresponses.NewFailureResponse(err, http.StatusBadRequest, "invalid-search-indexes")
+}
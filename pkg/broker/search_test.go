@@ -0,0 +1,179 @@
+package broker
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/mongodb/mongodb-atlas-service-broker/pkg/atlas"
+	"github.com/pivotal-cf/brokerapi"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProvisionSearchIndexes(t *testing.T) {
+	broker, client, ctx := setupTest()
+
+	params := `{
+		"searchIndexes": [{
+			"name": "default",
+			"database": "db",
+			"collectionName": "movies",
+			"mappings": {
+				"dynamic": true
+			}
+		}]
+	}`
+
+	instanceID := "instance"
+	res, err := broker.Provision(ctx, instanceID, brokerapi.ProvisionDetails{
+		PlanID:        testPlanID,
+		ServiceID:     testServiceID,
+		RawParameters: []byte(params),
+	}, true)
+
+	assert.NoError(t, err)
+	assert.Empty(t, client.SearchIndexes[instanceID], "Expected indexes to not be created before the cluster exists")
+
+	// The indexes should be created once the cluster is idle.
+	client.SetClusterState(instanceID, atlas.ClusterStateIdle)
+	resp, err := broker.LastOperation(ctx, instanceID, brokerapi.PollDetails{
+		OperationData: res.OperationData,
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, brokerapi.InProgress, resp.State)
+	assert.Len(t, client.SearchIndexes[instanceID], 1)
+
+	// Index still building.
+	resp, err = broker.LastOperation(ctx, instanceID, brokerapi.PollDetails{
+		OperationData: res.OperationData,
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, brokerapi.InProgress, resp.State)
+	assert.Len(t, client.SearchIndexes[instanceID], 1, "Expected index to not be created twice")
+
+	client.SetSearchIndexStatus(instanceID, atlas.SearchIndexStatusSteady)
+	resp, err = broker.LastOperation(ctx, instanceID, brokerapi.PollDetails{
+		OperationData: res.OperationData,
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, brokerapi.Succeeded, resp.State)
+}
+
+func TestUpdateSearchIndexes(t *testing.T) {
+	broker, client, ctx := setupTest()
+
+	instanceID := "instance"
+	provision, err := broker.Provision(ctx, instanceID, brokerapi.ProvisionDetails{
+		PlanID:    testPlanID,
+		ServiceID: testServiceID,
+		RawParameters: []byte(`{
+			"searchIndexes": [
+				{"name": "old", "database": "db", "collectionName": "movies"},
+				{"name": "changed", "database": "db", "collectionName": "movies", "analyzer": "lucene.standard"}
+			]
+		}`),
+	}, true)
+
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	client.SetClusterState(instanceID, atlas.ClusterStateIdle)
+	broker.LastOperation(ctx, instanceID, brokerapi.PollDetails{
+		OperationData: provision.OperationData,
+	})
+
+	// Indexes created by users aren't managed by the broker.
+	client.CreateSearchIndex(instanceID, atlas.SearchIndex{Name: "custom", Database: "db", CollectionName: "movies"})
+	client.CreateSearchIndex(instanceID, atlas.SearchIndex{Name: "other", Database: "db", CollectionName: "other"})
+
+	params := `{
+		"searchIndexes": [
+			{"name": "changed", "database": "db", "collectionName": "movies", "analyzer": "lucene.english"},
+			{"name": "new", "database": "db", "collectionName": "movies"}
+		]
+	}`
+
+	res, err := broker.Update(ctx, instanceID, brokerapi.UpdateDetails{
+		ServiceID:     testServiceID,
+		RawParameters: []byte(params),
+	}, true)
+
+	assert.NoError(t, err)
+
+	names := map[string]string{}
+	for _, index := range client.SearchIndexes[instanceID] {
+		names[index.Name] = index.Analyzer
+	}
+
+	// Only indexes created by the broker are deleted. Indexes on collections
+	// which aren't referenced should be left alone.
+	assert.Equal(t, map[string]string{
+		"changed": "lucene.english",
+		"custom":  "",
+		"new":     "",
+		"other":   "",
+	}, names)
+
+	client.SetClusterState(instanceID, atlas.ClusterStateIdle)
+	client.SetSearchIndexStatus(instanceID, atlas.SearchIndexStatusFailed)
+	resp, err := broker.LastOperation(ctx, instanceID, brokerapi.PollDetails{
+		OperationData: res.OperationData,
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, brokerapi.Failed, resp.State)
+	assert.NotEmpty(t, resp.Description)
+}
+
+// rejectingSearchIndexClient is a mock client for which Atlas rejects the
+// search indexes.
+type rejectingSearchIndexClient struct {
+	MockAtlasClient
+}
+
+func (c rejectingSearchIndexClient) CreateSearchIndex(clusterName string, index atlas.SearchIndex) (*atlas.SearchIndex, error) {
+	return nil, &atlas.Error{StatusCode: http.StatusBadRequest, Code: "INVALID_ATTRIBUTE", Description: "Invalid analyzer"}
+}
+
+func TestProvisionSearchIndexesRejected(t *testing.T) {
+	broker, client, ctx := setupTest()
+
+	instanceID := "instance"
+	res, err := broker.Provision(ctx, instanceID, brokerapi.ProvisionDetails{
+		PlanID:        testPlanID,
+		ServiceID:     testServiceID,
+		RawParameters: []byte(`{"searchIndexes": [{"name": "default", "database": "db", "collectionName": "movies", "analyzer": "invalid"}]}`),
+	}, true)
+
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	// Indexes rejected by Atlas fail the operation.
+	client.SetClusterState(instanceID, atlas.ClusterStateIdle)
+	rejectingCtx := context.WithValue(context.Background(), ContextKeyAtlasClient, rejectingSearchIndexClient{client})
+	resp, err := broker.LastOperation(rejectingCtx, instanceID, brokerapi.PollDetails{
+		OperationData: res.OperationData,
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, brokerapi.Failed, resp.State)
+	assert.Contains(t, resp.Description, "Invalid analyzer")
+}
+
+func TestInvalidSearchIndexes(t *testing.T) {
+	broker, client, ctx := setupTest()
+
+	_, err := broker.Provision(ctx, "instance", brokerapi.ProvisionDetails{
+		PlanID:        testPlanID,
+		ServiceID:     testServiceID,
+		RawParameters: []byte(`{"searchIndexes": [{"name": "default"}]}`),
+	}, true)
+
+	assert.Error(t, err)
+	assert.Len(t, client.Clusters, 0, "Expected no clusters to be created")
+}
@@ -0,0 +1,128 @@
+package broker
+
+import (
+	"encoding/json"
+
+	"github.com/mongodb/mongodb-atlas-service-broker/pkg/atlas"
+	"github.com/pivotal-cf/brokerapi"
+)
+
+// defaultServerlessProviderName is the cloud provider serverless instances
+// are backed by unless the parameters specify otherwise.
+const defaultServerlessProviderName = "AWS"
+
+// isServerlessService checks if a service ID belongs to the serverless
+// service. Requests for the serverless service are routed to serverless
+// instances instead of clusters.
+func isServerlessService(serviceID string) bool {
+	return serviceID == serverlessService.ID
+}
+
+// provisionServerless will create a new Atlas serverless instance with the
+// instance ID as its name.
+func (b Broker) provisionServerless(client atlas.Client, instanceID string, details brokerapi.ProvisionDetails) (spec brokerapi.ProvisionedServiceSpec, err error) {
+	err = validatePlanID(serverlessService, details.PlanID)
+	if err != nil {
+		return
+	}
+
+	instance, err := serverlessInstanceFromParams(instanceID, details.RawParameters)
+	if err != nil {
+		b.logger.Errorw("Couldn't create serverless instance from the passed parameters", "error", err, "instance_id", instanceID, "details", details)
+		return
+	}
+
+	// Fill in the provider settings which are required on creation.
+	if instance.ProviderSettings == nil {
+		instance.ProviderSettings = &atlas.ServerlessProviderSettings{}
+	}
+
+	instance.ProviderSettings.ProviderName = atlas.ProviderNameServerless
+	if instance.ProviderSettings.BackingProviderName == "" {
+		instance.ProviderSettings.BackingProviderName = defaultServerlessProviderName
+	}
+
+	if instance.ProviderSettings.RegionName == "" {
+		instance.ProviderSettings.RegionName = defaultRegions[instance.ProviderSettings.BackingProviderName]
+	}
+
+	resultingInstance, err := client.CreateServerlessInstance(*instance)
+	if err != nil {
+		b.logger.Errorw("Failed to create Atlas serverless instance", "error", err, "instance", instance)
+		err = atlasToAPIError(err)
+		return
+	}
+
+	b.logger.Infow("Successfully started Atlas serverless instance creation process", "instance_id", instanceID, "instance", resultingInstance)
+
+	operationData, err := operation{Type: OperationProvision, Kind: instanceKindServerless}.encode()
+	if err != nil {
+		return
+	}
+
+	return brokerapi.ProvisionedServiceSpec{
+		IsAsync:       true,
+		OperationData: operationData,
+		DashboardURL:  client.GetDashboardURL(resultingInstance.Name),
+	}, nil
+}
+
+// updateServerless will change the configuration of an existing Atlas
+// serverless instance asynchronously.
+func (b Broker) updateServerless(client atlas.Client, instanceID string, details brokerapi.UpdateDetails) (spec brokerapi.UpdateServiceSpec, err error) {
+	if details.PlanID != "" {
+		err = validatePlanID(serverlessService, details.PlanID)
+		if err != nil {
+			return
+		}
+	}
+
+	instance, err := serverlessInstanceFromParams(instanceID, details.RawParameters)
+	if err != nil {
+		return
+	}
+
+	resultingInstance, err := client.UpdateServerlessInstance(*instance)
+	if err != nil {
+		b.logger.Errorw("Failed to update Atlas serverless instance", "error", err, "instance", instance)
+		err = atlasToAPIError(err)
+		return
+	}
+
+	b.logger.Infow("Successfully started Atlas serverless instance update process", "instance_id", instanceID, "instance", resultingInstance)
+
+	operationData, err := operation{Type: OperationUpdate, Kind: instanceKindServerless}.encode()
+	if err != nil {
+		return
+	}
+
+	return brokerapi.UpdateServiceSpec{
+		IsAsync:       true,
+		OperationData: operationData,
+		DashboardURL:  client.GetDashboardURL(resultingInstance.Name),
+	}, nil
+}
+
+// serverlessInstanceFromParams will construct a serverless instance from an
+// instance ID and raw parameters. All the configuration available for
+// serverless instances in the Atlas API can be passed as "serverless".
+func serverlessInstanceFromParams(instanceID string, rawParams []byte) (*atlas.ServerlessInstance, error) {
+	// Set up a params object which will be used for deserialiation.
+	params := struct {
+		Serverless *atlas.ServerlessInstance `json:"serverless"`
+	}{
+		&atlas.ServerlessInstance{},
+	}
+
+	// If params were passed we unmarshal them into the params object.
+	if len(rawParams) > 0 {
+		err := json.Unmarshal(rawParams, &params)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Add the instance ID as the name of the instance.
+	params.Serverless.Name = NormalizeClusterName(instanceID)
+	return params.Serverless, nil
+}
@@ -0,0 +1,107 @@
+package broker
+
+import (
+	"github.com/mongodb/mongodb-atlas-service-broker/pkg/atlas"
+)
+
+// defaultNumShards is the number of shards sharded clusters are created with
+// unless the parameters specify otherwise.
+const defaultNumShards = 2
+
+// defaultZoneName is the name of the zone global clusters are created with
+// unless the parameters specify their zones.
+const defaultZoneName = "Zone 1"
+
+// defaultRegions contains the region used for the default zone of global
+// clusters if no region has been specified.
+var defaultRegions = map[string]string{
+	"AWS":   "US_EAST_1",
+	"GCP":   "CENTRAL_US",
+	"AZURE": "US_EAST_2",
+}
+
+// applyShardingDefaults will fill in the settings required by Atlas to create
+// sharded and global clusters if they haven't been specified by the user.
+// Sharded clusters default to two shards and global clusters default to a
+// single zone in the cluster's region.
+func applyShardingDefaults(cluster *atlas.Cluster) {
+	if len(cluster.ReplicationSpecs) > 0 {
+		return
+	}
+
+	switch cluster.ClusterType {
+	case atlas.ClusterTypeSharded:
+		if cluster.NumShards == 0 {
+			cluster.NumShards = defaultNumShards
+		}
+	case atlas.ClusterTypeGeoSharded:
+		var region string
+		if cluster.ProviderSettings != nil {
+			region = cluster.ProviderSettings.RegionName
+			if region == "" {
+				region = defaultRegions[cluster.ProviderSettings.ProviderName]
+			}
+		}
+
+		numShards := cluster.NumShards
+		if numShards == 0 {
+			numShards = 1
+		}
+
+		cluster.ReplicationSpecs = []atlas.ReplicationSpec{
+			atlas.ReplicationSpec{
+				NumShards: numShards,
+				ZoneName:  defaultZoneName,
+				RegionsConfig: map[string]atlas.RegionsConfig{
+					region: atlas.RegionsConfig{
+						ElectableNodes: 3,
+						ReadOnlyNodes:  0,
+						Priority:       7,
+					},
+				},
+			},
+		}
+	}
+}
+
+// mergeReplicationSpecs will combine the replication specs of an existing
+// cluster with the specs passed during an update. Updated specs are matched
+// with existing ones by ID or zone name and inherit their ID, specs without a
+// zone name match any existing spec. For global clusters existing specs which
+// aren't part of the update are kept, as Atlas does not allow zones to be
+// removed, and specs which don't match any existing ones are added as new zones.
+func mergeReplicationSpecs(existingCluster *atlas.Cluster, updated []atlas.ReplicationSpec) []atlas.ReplicationSpec {
+	existing := existingCluster.ReplicationSpecs
+	merged := make([]atlas.ReplicationSpec, 0, len(existing)+len(updated))
+	matched := make([]bool, len(existing))
+
+	for _, spec := range updated {
+		for i, existingSpec := range existing {
+			if matched[i] {
+				continue
+			}
+
+			sameID := spec.ID != "" && spec.ID == existingSpec.ID
+			sameZone := spec.ID == "" && (spec.ZoneName == "" || spec.ZoneName == existingSpec.ZoneName)
+			if sameID || sameZone {
+				spec.ID = existingSpec.ID
+				matched[i] = true
+				break
+			}
+		}
+
+		merged = append(merged, spec)
+	}
+
+	if existingCluster.ClusterType != atlas.ClusterTypeGeoSharded {
+		return merged
+	}
+
+	for i, existingSpec := range existing {
+		if !matched[i] {
+			merged = append(merged, existingSpec)
+		}
+	}
+
+	return merged
+}
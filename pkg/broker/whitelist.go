@@ -20,7 +20,7 @@ func ReadWhitelistFile(path string) (Whitelist, error) {
 	}
 
 	for whitelistProviderName, _ := range whitelist {
-		var isValid bool
+		_, isValid := standaloneServices[whitelistProviderName]
 		for _, providerName := range providerNames {
 			if whitelistProviderName == providerName {
 				isValid = true
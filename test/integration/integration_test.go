@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"os"
+	"strings"
 	"testing"
 
 	"github.com/google/uuid"
@@ -446,21 +447,13 @@ func TestBind(t *testing.T) {
 	}
 
 	// Ensure the MongoDB username is the binding ID, that the password is not
-	// empty and that the connection URI matches the cluster's.
+	// empty and that the connection URI points to the cluster.
 	assert.Equal(t, bindingID, credentials.Username)
 	assert.NotEmpty(t, credentials.Password, "Expected non-empty password")
-	assert.Equal(t, cluster.SrvAddress, credentials.URI)
+	assert.Contains(t, credentials.URI, strings.TrimPrefix(cluster.SrvAddress, "mongodb+srv://"))
 
 	// Ensure the cluster can be connected to with the generated credentials.
-	// We need to reset the auth source using a parameter otherwise the Go
-	// MongoDB library will fail to parse the connection string.
-	conn := options.Client().
-		ApplyURI(credentials.URI + "/?authSource=").
-		SetAuth(options.Credential{
-			Username:    credentials.Username,
-			Password:    credentials.Password,
-			PasswordSet: true,
-		})
+	conn := options.Client().ApplyURI(credentials.URI)
 
 	// Try connecting to the cluster to ensure that the credentials are
 	// valid. There is sometimes a slight delay before the user is ready so this
@@ -613,5 +606,5 @@ func teardownInstance(instanceID string) {
 }
 
 func teardownBinding(bindingID string) {
-	client.DeleteUser(bindingID)
+	client.DeleteUser(atlas.AuthDatabaseAdmin, bindingID)
 }